@@ -2,26 +2,31 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"new_service/internal/config"
-	addPost "new_service/internal/handlers/add_post"
-	"new_service/internal/handlers/auth"
-	deletePost "new_service/internal/handlers/delete"
-	getNextPosts "new_service/internal/handlers/getPosts"
-	"new_service/internal/handlers/logout"
-	"new_service/internal/handlers/registration"
+	"new_service/internal/handlers/health"
+	"new_service/internal/lib/blobstore"
+	"new_service/internal/lib/imageproc"
 	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/metrics"
+	"new_service/internal/lib/oidc"
+	"new_service/internal/lib/password"
+	"new_service/internal/lib/ratelimit"
+	"new_service/internal/lib/tracing"
+	"new_service/internal/repository/cache"
+	"new_service/internal/repository/migrator"
 	"new_service/internal/repository/storage"
-	jwt_auth "new_service/pkg/auth"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/gin-gonic/gin"
-	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -31,25 +36,108 @@ const (
 	envProd  = "prod"
 )
 
+const usage = `usage:
+  server [serve] [flags]             start http server
+  server config print [--redacted]   print effective configuration
+  server migrate up [flags]          apply all pending migrations
+  server migrate down [N] [flags]    roll back N migrations (default 1)
+  server migrate status [flags]      show applied and pending migrations
+  server migrate force V [flags]     set version V and clear dirty flag
+
+run "server serve -h" to list configuration flags`
+
 func main() {
-	os.Setenv("CONFIG_PATH", "./config/local.yaml")
-	cfg := config.MustLoad()
+	command, args := parseCommand(os.Args[1:])
+
+	switch command {
+	case "serve":
+		runServer(config.MustLoad(args))
+	case "config print":
+		redacted := false
+		args = slices.DeleteFunc(args, func(arg string) bool {
+			if arg == "--redacted" || arg == "-redacted" {
+				redacted = true
+				return true
+			}
+			return false
+		})
+		cfg := config.MustLoad(args)
+		if err := cfg.Print(os.Stdout, redacted); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "migrate":
+		runMigrate(args)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// parseCommand отделяет подкоманду от флагов; без подкоманды запускается сервер
+func parseCommand(args []string) (string, []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "serve", args
+	}
+	if args[0] == "serve" {
+		return "serve", args[1:]
+	}
+	if args[0] == "config" && len(args) > 1 && args[1] == "print" {
+		return "config print", args[2:]
+	}
+	if args[0] == "migrate" {
+		return "migrate", args[1:]
+	}
+	return "", args
+}
 
+func runServer(cfg *config.Config) {
 	log := setUpLogger(cfg.Env)
 	log.Info("Logger started")
 
-	storage, err := storage.New(cfg.PostgresConnString)
+	if cfg.Migrations.OnStart {
+		if err := applyMigrations(cfg); err != nil {
+			log.Error("failed to apply migrations", sl.Error(err))
+			os.Exit(1)
+		}
+		log.Info("migrations applied")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Error("failed to init tracing", sl.Error(err))
+		os.Exit(1)
+	}
+
+	hasher, err := password.NewHasher(cfg.PasswordHashing)
+	if err != nil {
+		log.Error("invalid password hashing config", sl.Error(err))
+		os.Exit(1)
+	}
+
+	storage, err := storage.New(cfg.PostgresConnString, cfg.PostgresPool, hasher)
 	if err != nil {
 		log.Info("Failed to connect to database", sl.Error(err))
 		os.Exit(1)
 	}
 	defer storage.Conn.Close()
+	metrics.RegisterPool(storage.Conn)
 
 	rdb := redis.NewClient(&redis.Options{
-		Addr: cfg.RedisAddress,
-		DB:   0,
+		Addr:         cfg.RedisAddress,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		PoolSize:     cfg.Redis.PoolSize,
+		DialTimeout:  cfg.Redis.DialTimeout,
+		ReadTimeout:  cfg.Redis.ReadTimeout,
+		WriteTimeout: cfg.Redis.WriteTimeout,
 	})
 	defer rdb.Close()
+	rdb.AddHook(metrics.RedisHook{})
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		log.Error("failed to instrument redis tracing", sl.Error(err))
+		os.Exit(1)
+	}
 
 	_, err = rdb.Ping(context.Background()).Result()
 	if err != nil {
@@ -58,30 +146,82 @@ func main() {
 	}
 	log.Info("started redis db")
 
-	router := gin.Default()
+	passwordPolicy, err := password.NewPolicy(cfg.PasswordPolicy)
+	if err != nil {
+		log.Error("failed to load password policy", sl.Error(err))
+		os.Exit(1)
+	}
+
+	blobStore, err := blobstore.New(context.Background(), cfg.Media)
+	if err != nil {
+		log.Error("failed to init media storage", sl.Error(err))
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(cfg.Media.Processing.SpoolDir, 0o755); err != nil {
+		log.Error("failed to create spool dir", sl.Error(err))
+		os.Exit(1)
+	}
+	if stale, err := storage.FailStaleMedia(context.Background(), cfg.Media.Processing.StaleAfter); err != nil {
+		log.Error("failed to fail stale media", sl.Error(err))
+	} else if stale > 0 {
+		log.Info("marked interrupted media as failed", slog.Int64("count", stale))
+	}
+
+	imageProcessor := imageproc.New(log, cfg.Media.Processing, storage, blobStore)
+	imageProcessor.Start(context.Background())
 
-	router.POST("/registration", registration.New(storage, log))
-	router.POST("/auth", auth.New(log, cfg, storage))
+	var oidcProvider *oidc.Provider
+	if cfg.OIDC.Enabled {
+		oidcProvider, err = oidc.New(context.Background(), cfg.OIDC, rdb)
+		if err != nil {
+			log.Error("failed to init oidc provider", sl.Error(err))
+			os.Exit(1)
+		}
+	}
 
-	protected := router.Group("/protected")
-	protected.Use(jwt_auth.JWTAuthMiddleware(cfg.JWTSecret, rdb))
-	{
-		protected.POST("/save-post", addPost.New(log, storage))
-		protected.GET("/next-posts", getNextPosts.New(log, storage))
-		protected.GET("/logout", logout.New(log, rdb, cfg.JWTSecret))
-		protected.DELETE("/delete-post", deletePost.New(log, storage))
+	readiness := &health.Readiness{}
+	router, err := setUpRouter(log, cfg, routerDeps{
+		storage:        storage,
+		posts:          cache.New(log, storage, rdb, cfg.Cache),
+		rdb:            rdb,
+		hasher:         hasher,
+		passwordPolicy: passwordPolicy,
+		blobStore:      blobStore,
+		imageProcessor: imageProcessor,
+		oidcProvider:   oidcProvider,
+		limiter:        ratelimit.New(log, rdb, cfg.RateLimit),
+		readiness:      readiness,
+		checks: map[string]health.Check{
+			"postgres": storage.Conn.Ping,
+			"redis": func(ctx context.Context) error {
+				return rdb.Ping(ctx).Err()
+			},
+		},
+	})
+	if err != nil {
+		log.Error("failed to set up router", sl.Error(err))
+		os.Exit(1)
 	}
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go runAccountPurger(jobsCtx, log, storage, blobStore, cfg.AccountDeletion)
+
 	srv := &http.Server{
-		Addr:    cfg.Address,
-		Handler: router,
+		Addr:              cfg.HTTPServer.Address,
+		Handler:           router,
+		ReadTimeout:       cfg.HTTPServer.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPServer.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPServer.WriteTimeout,
+		IdleTimeout:       cfg.HTTPServer.IdleTimeout,
 	}
 
 	// Канал для ошибок из горутины сервера
 	serverErrors := make(chan error, 1)
 
 	go func() {
-		log.Info("starting server", slog.String("address", cfg.Address))
+		log.Info("starting server", slog.String("address", cfg.HTTPServer.Address))
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Error("failed to start server", sl.Error(err))
 			serverErrors <- err
@@ -102,17 +242,112 @@ func main() {
 
 	log.Info("initiating graceful shutdown")
 
+	// Сначала снимаем готовность, чтобы балансировщик успел убрать инстанс из ротации
+	readiness.Drain()
+	if cfg.Health.DrainDelay > 0 {
+		log.Info("draining traffic", slog.Duration("delay", cfg.Health.DrainDelay))
+		time.Sleep(cfg.Health.DrainDelay)
+	}
+
 	// КРИТИЧНО: используем контекст с таймаутом
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
 	defer cancel()
 
+	stopJobs()
+
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("shutdown error", sl.Error(err))
 	}
 
+	imageProcessor.Stop()
+
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("failed to flush traces", sl.Error(err))
+	}
+
 	log.Info("server stopped gracefully")
 }
 
+// runMigrate выполняет migrate up|down|status|force; аргументы действия идут перед флагами
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	action, args := args[0], args[1:]
+
+	number := 0
+	switch action {
+	case "up", "status":
+	case "down", "force":
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid number %q\n", args[0])
+				os.Exit(2)
+			}
+			number, args = n, args[1:]
+		} else if action == "down" {
+			number = 1
+		} else {
+			fmt.Fprintln(os.Stderr, "migrate force requires a version")
+			os.Exit(2)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg := config.MustLoad(args)
+	m, err := migrator.New(cfg.PostgresConnString, cfg.Migrations.LockTimeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer m.Close()
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		err = m.Down(ctx, number)
+	case "force":
+		err = m.Force(ctx, number)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("version: %d, dirty: %t\n", status.Version, status.Dirty)
+	if action == "status" {
+		for _, migration := range status.Migrations {
+			mark := " "
+			if migration.Applied {
+				mark = "x"
+			}
+			fmt.Printf("[%s] %s\n", mark, migration.Name)
+		}
+	}
+}
+
+// applyMigrations применяет миграции при старте; реплики ждут друг друга на advisory lock
+func applyMigrations(cfg *config.Config) error {
+	m, err := migrator.New(cfg.PostgresConnString, cfg.Migrations.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Up(context.Background())
+}
+
 func setUpLogger(env string) *slog.Logger {
 	var log *slog.Logger
 	switch env {
@@ -128,6 +363,48 @@ func setUpLogger(env string) *slog.Logger {
 		log = slog.New(
 			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
 		)
+	default:
+		// Конфиг проверяет env, но без логгера сервер упал бы на первой записи
+		log = slog.New(
+			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
+		)
+		log.Warn("unknown env, falling back to info level json logs", slog.String("env", env))
 	}
 	return log
 }
+
+// runAccountPurger окончательно удаляет аккаунты, у которых истёк срок ожидания удаления, и их файлы
+func runAccountPurger(ctx context.Context, log *slog.Logger, storage *storage.Storage, blobStore blobstore.BlobStore, cfg config.AccountDeletion) {
+	ticker := time.NewTicker(cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purgeDeletedUsers(ctx, log, storage, blobStore, cfg)
+		}
+	}
+}
+
+// purgeDeletedUsers обрабатывает пачки, пока не останется аккаунтов с истёкшим сроком
+func purgeDeletedUsers(ctx context.Context, log *slog.Logger, storage *storage.Storage, blobStore blobstore.BlobStore, cfg config.AccountDeletion) {
+	var total int64
+	for ctx.Err() == nil {
+		batchCtx, cancel := context.WithTimeout(ctx, cfg.PurgeTimeout)
+		purged, err := storage.PurgeDeletedUsers(batchCtx, blobStore, cfg.PurgeBatchSize)
+		cancel()
+		if err != nil {
+			log.Error("failed to purge deleted accounts", sl.Error(err))
+			break
+		}
+		total += purged
+		if purged < int64(cfg.PurgeBatchSize) {
+			break
+		}
+	}
+	if total > 0 {
+		log.Info("purged deleted accounts", slog.Int64("count", total))
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"new_service/internal/config"
+	"new_service/internal/handlers/account"
+	addPost "new_service/internal/handlers/add_post"
+	"new_service/internal/handlers/auth"
+	deletePost "new_service/internal/handlers/delete"
+	getNextPosts "new_service/internal/handlers/getPosts"
+	getPost "new_service/internal/handlers/get_post"
+	"new_service/internal/handlers/health"
+	hidePost "new_service/internal/handlers/hide"
+	"new_service/internal/handlers/logout"
+	"new_service/internal/handlers/media"
+	oidcLogin "new_service/internal/handlers/oidc"
+	"new_service/internal/handlers/openapi"
+	changePassword "new_service/internal/handlers/password"
+	"new_service/internal/handlers/profile"
+	"new_service/internal/handlers/registration"
+	setRole "new_service/internal/handlers/role"
+	"new_service/internal/lib/apperr"
+	"new_service/internal/lib/blobstore"
+	"new_service/internal/lib/bruteforce"
+	"new_service/internal/lib/cors"
+	"new_service/internal/lib/deprecation"
+	"new_service/internal/lib/httpcache"
+	"new_service/internal/lib/imageproc"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/metrics"
+	"new_service/internal/lib/oidc"
+	"new_service/internal/lib/password"
+	"new_service/internal/lib/ratelimit"
+	"new_service/internal/lib/tracing"
+	"new_service/internal/repository/cache"
+	"new_service/internal/repository/storage"
+	jwt_auth "new_service/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// routerDeps — зависимости обработчиков, созданные в runServer
+type routerDeps struct {
+	storage        *storage.Storage
+	posts          *cache.Storage
+	rdb            *redis.Client
+	hasher         *password.Hasher
+	passwordPolicy *password.Policy
+	blobStore      blobstore.BlobStore
+	imageProcessor *imageproc.Processor
+	// oidcProvider равен nil, если вход через OIDC выключен
+	oidcProvider *oidc.Provider
+	limiter      *ratelimit.Limiter
+	readiness    *health.Readiness
+	checks       map[string]health.Check
+}
+
+// setUpRouter регистрирует все маршруты; при изменении маршрутов обновите internal/handlers/openapi/openapi.json
+func setUpRouter(log *slog.Logger, cfg *config.Config, deps routerDeps) (*gin.Engine, error) {
+	storage := deps.storage
+
+	// Вместо логгера gin access-лог пишет sl.RequestLogger
+	router := gin.New()
+	// От IP клиента зависят лимиты запросов и защита от перебора паролей
+	if err := router.SetTrustedProxies(cfg.HTTPServer.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	router.Use(
+		apperr.Recovery(log),
+		otelgin.Middleware(cfg.Tracing.ServiceName, otelgin.WithFilter(tracing.SkipProbes)),
+		sl.RequestLogger(log),
+		metrics.Middleware(),
+		// Preflight-запросы завершаются здесь, не доходя до маршрутов
+		cors.Middleware(cfg.CORS, cfg.CSRF),
+		httpcache.Compress(),
+		apperr.Middleware(log),
+	)
+	router.GET("/metrics", metrics.Handler())
+
+	router.GET("/healthz", health.NewLive())
+	router.GET("/readyz", health.NewReady(log, deps.readiness, cfg.Health.CheckTimeout, deps.checks))
+
+	router.GET("/openapi.json", openapi.NewSpec())
+	router.GET("/docs", openapi.NewDocs("/openapi.json"))
+
+	if localStore, ok := deps.blobStore.(*blobstore.LocalStore); ok {
+		router.Static(cfg.Media.PublicBaseURL, localStore.Dir())
+	}
+
+	loginGuard := bruteforce.New(deps.rdb, cfg.BruteForce)
+
+	// Обработчики общие для /api/v1 и устаревших маршрутов
+	register := registration.New(storage, log, deps.passwordPolicy)
+	login := auth.New(log, cfg, storage, deps.hasher, loginGuard)
+	getProfile := profile.NewPublic(log, storage)
+	updateProfile := profile.NewUpdate(log, storage, cfg.Profiles.UsernameRedirectTTL)
+	createPost := addPost.New(log, deps.posts)
+	listPosts := getNextPosts.New(log, deps.posts)
+	deletePostHandler := deletePost.New(log, deps.posts)
+	hidePostHandler := hidePost.New(log, deps.posts)
+	logoutHandler := logout.New(log, deps.rdb, cfg.JWTSecret)
+	changePasswordHandler := changePassword.New(log, cfg, storage, deps.passwordPolicy, deps.hasher, deps.rdb, loginGuard)
+	exportAccount := account.NewExport(log, storage)
+	deleteAccount := account.NewDelete(log, storage, deps.hasher, deps.rdb, loginGuard, cfg.AccountDeletion.GracePeriod, cfg.Cookie)
+	restoreAccount := account.NewRestore(log, storage)
+	uploadMedia := media.NewUpload(log, cfg.Media, storage, deps.imageProcessor)
+	getMedia := media.NewGet(log, storage)
+	setRoleHandler := setRole.New(log, storage, deps.rdb)
+
+	authLimit := ratelimit.Policy{Name: "auth", Requests: cfg.RateLimit.AuthRequests, Period: cfg.RateLimit.AuthPeriod}
+	writeLimit := ratelimit.Policy{Name: "write", Requests: cfg.RateLimit.WriteRequests, Period: cfg.RateLimit.WritePeriod}
+	readLimit := ratelimit.Policy{Name: "read", Requests: cfg.RateLimit.ReadRequests, Period: cfg.RateLimit.ReadPeriod}
+	ipLimit := ratelimit.Policy{Name: "ip", Requests: cfg.RateLimit.IPRequests, Period: cfg.RateLimit.IPPeriod}
+	limitAuth := deps.limiter.Middleware(authLimit)
+	limitAnonymous := deps.limiter.Middleware(readLimit)
+
+	// До JWT user_id ещё не выставлен, поэтому первый лимит считает запросы по IP, включая запросы
+	// без токена или с неверным токеном. Лимит после JWT считает авторизованные запросы по user_id
+	requireAuth := []gin.HandlerFunc{
+		deps.limiter.Middleware(ipLimit),
+		jwt_auth.CSRFMiddleware(cfg.CSRF, cfg.Cookie),
+		jwt_auth.JWTAuthMiddleware(cfg.JWTSecret, deps.rdb),
+		deps.limiter.ByMethod(readLimit, writeLimit),
+		httpcache.CacheControl(httpcache.Private),
+	}
+	publicCache := httpcache.CacheControl(httpcache.Public)
+	requireModerator := jwt_auth.RequireRole(jwt_auth.RoleModerator, jwt_auth.RoleAdmin)
+	requireAdmin := jwt_auth.RequireRole(jwt_auth.RoleAdmin)
+
+	// OIDC-маршруты зарегистрированы у провайдера, поэтому остаются без версии
+	if deps.oidcProvider != nil {
+		router.GET("/auth/oidc/login", limitAuth, oidcLogin.NewLogin(log, cfg, deps.oidcProvider))
+		router.GET("/auth/oidc/callback", limitAuth, oidcLogin.NewCallback(log, cfg, deps.oidcProvider, storage))
+	}
+
+	v1 := router.Group("/api/v1")
+	{
+		v1.POST("/users", limitAuth, register)
+		v1.GET("/users/:username", limitAnonymous, publicCache, getProfile)
+		v1.GET("/public/posts/:id", limitAnonymous, publicCache, getPost.NewPublic(log, deps.posts))
+		v1.POST("/sessions", limitAuth, login)
+	}
+
+	v1Protected := v1.Group("", requireAuth...)
+	{
+		v1Protected.POST("/sessions/logout", logoutHandler)
+
+		v1Protected.GET("/posts", listPosts)
+		v1Protected.POST("/posts", createPost)
+		v1Protected.GET("/posts/:id", getPost.New(log, deps.posts))
+		v1Protected.DELETE("/posts/:id", deletePostHandler)
+
+		v1Protected.PATCH("/me", updateProfile)
+		v1Protected.PUT("/me/password", changePasswordHandler)
+		v1Protected.GET("/me/export", exportAccount)
+		v1Protected.POST("/me/deletion", deleteAccount)
+		v1Protected.DELETE("/me/deletion", restoreAccount)
+
+		v1Protected.POST("/media", uploadMedia)
+		v1Protected.GET("/media/:id", getMedia)
+
+		v1Protected.PUT("/moderation/posts/:id/visibility", requireModerator, hidePostHandler)
+		v1Protected.PUT("/admin/users/:id/role", requireAdmin, setRoleHandler)
+	}
+
+	// Устаревшие маршруты работают до даты Sunset, замены описаны в /docs
+	legacy := router.Group("", deprecation.Middleware(cfg.LegacyAPI, "/docs"))
+	{
+		legacy.POST("/registration", limitAuth, register)
+		legacy.POST("/auth", limitAuth, login)
+		legacy.GET("/users/:username", limitAnonymous, publicCache, getProfile)
+	}
+
+	protected := legacy.Group("/protected", requireAuth...)
+	{
+		protected.POST("/save-post", createPost)
+		protected.GET("/next-posts", listPosts)
+		protected.POST("/logout", logoutHandler)
+		protected.GET("/logout", jwt_auth.RequireCSRFToken(cfg.CSRF), logoutHandler)
+		protected.DELETE("/delete-post", deletePostHandler)
+		protected.POST("/password", changePasswordHandler)
+		protected.GET("/account/export", exportAccount)
+		protected.DELETE("/account", deleteAccount)
+		protected.POST("/account/restore", restoreAccount)
+		protected.PATCH("/profile", updateProfile)
+		protected.POST("/media", uploadMedia)
+		protected.GET("/media/:id", getMedia)
+	}
+
+	moderation := protected.Group("/moderation", requireModerator)
+	{
+		moderation.POST("/hide-post", hidePostHandler)
+	}
+
+	admin := protected.Group("/admin", requireAdmin)
+	{
+		admin.POST("/set-role", setRoleHandler)
+	}
+
+	return router, nil
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"new_service/internal/config"
+	"new_service/internal/handlers/account"
+	addPost "new_service/internal/handlers/add_post"
+	"new_service/internal/handlers/auth"
+	deletePost "new_service/internal/handlers/delete"
+	"new_service/internal/handlers/health"
+	hidePost "new_service/internal/handlers/hide"
+	"new_service/internal/handlers/openapi"
+	changePassword "new_service/internal/handlers/password"
+	"new_service/internal/handlers/profile"
+	"new_service/internal/handlers/registration"
+	setRole "new_service/internal/handlers/role"
+	"new_service/internal/handlers/structs"
+	"new_service/internal/lib/oidc"
+	"new_service/internal/lib/password"
+	"new_service/internal/lib/ratelimit"
+	"new_service/internal/lib/response"
+	"new_service/internal/models"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type specDocument struct {
+	Paths      map[string]map[string]specOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]specSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type specOperation struct {
+	Deprecated  bool            `json:"deprecated"`
+	Parameters  []specParameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema specSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema specSchema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+type specParameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required"`
+}
+
+type specSchema struct {
+	Ref        string                `json:"$ref"`
+	Required   []string              `json:"required"`
+	Properties map[string]specSchema `json:"properties"`
+	Items      *specSchema           `json:"items"`
+}
+
+// Маршруты, которые описывают не API, а раздачу файлов
+var undocumentedRoute = regexp.MustCompile(`\*filepath$`)
+
+var ginParam = regexp.MustCompile(`:([^/]+)`)
+
+func loadSpec(t *testing.T) specDocument {
+	t.Helper()
+	var spec specDocument
+	if err := json.Unmarshal(openapi.Spec, &spec); err != nil {
+		t.Fatalf("openapi.json is invalid: %v", err)
+	}
+	return spec
+}
+
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	t.Setenv("JWT_SECRET", "test-secret")
+	cfg, err := config.Load([]string{"-config", "../config/local.yaml"})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	hasher, err := password.NewHasher(cfg.PasswordHashing)
+	if err != nil {
+		t.Fatalf("failed to create hasher: %v", err)
+	}
+	policy, err := password.NewPolicy(cfg.PasswordPolicy)
+	if err != nil {
+		t.Fatalf("failed to create password policy: %v", err)
+	}
+
+	// Обработчики только регистрируются, поэтому хранилища не нужны;
+	// OIDC-провайдер передаётся, чтобы опциональные маршруты тоже попали в проверку
+	cfg.RateLimit.Disabled = true
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	router, err := setUpRouter(log, cfg, routerDeps{
+		hasher:         hasher,
+		passwordPolicy: policy,
+		oidcProvider:   &oidc.Provider{},
+		limiter:        ratelimit.New(log, nil, cfg.RateLimit),
+		readiness:      &health.Readiness{},
+	})
+	if err != nil {
+		t.Fatalf("failed to set up router: %v", err)
+	}
+	return router
+}
+
+func routeKey(method string, path string) string {
+	return method + " " + path
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	spec := loadSpec(t)
+	router := newTestRouter(t)
+
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		if undocumentedRoute.MatchString(route.Path) {
+			continue
+		}
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		registered[routeKey(route.Method, path)] = true
+
+		if _, ok := spec.Paths[path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("route %s %s is missing from openapi.json", route.Method, route.Path)
+		}
+	}
+
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if !registered[routeKey(strings.ToUpper(method), path)] {
+				t.Errorf("openapi.json describes %s %s, but the route is not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestSpecSchemas(t *testing.T) {
+	spec := loadSpec(t)
+
+	// Тело запроса и data успешного ответа для каждой операции с JSON
+	operations := []struct {
+		route    string
+		request  any
+		response any
+	}{
+		{route: "POST /api/v1/users", request: registration.Request{}, response: response.Message{}},
+		{route: "GET /api/v1/users/{username}", response: models.PublicProfile{}},
+		{route: "POST /api/v1/sessions", request: auth.Request{}, response: auth.Response{}},
+		{route: "POST /api/v1/sessions/logout", response: response.Message{}},
+		{route: "GET /api/v1/posts", response: models.DbPost{}},
+		{route: "POST /api/v1/posts", request: addPost.Request{}, response: response.Message{}},
+		{route: "GET /api/v1/posts/{id}", response: models.DbPost{}},
+		{route: "GET /api/v1/public/posts/{id}", response: models.DbPost{}},
+		{route: "DELETE /api/v1/posts/{id}", response: response.Message{}},
+		{route: "PATCH /api/v1/me", request: profile.UpdateRequest{}, response: response.Message{}},
+		{route: "PUT /api/v1/me/password", request: changePassword.Request{}, response: changePassword.Response{}},
+		{route: "POST /api/v1/me/deletion", request: account.DeleteRequest{}, response: account.DeleteResponse{}},
+		{route: "DELETE /api/v1/me/deletion", response: response.Message{}},
+		{route: "POST /api/v1/media", response: models.Media{}},
+		{route: "GET /api/v1/media/{id}", response: models.Media{}},
+		{route: "PUT /api/v1/moderation/posts/{id}/visibility", request: hidePost.VisibilityRequest{}, response: response.Message{}},
+		{route: "PUT /api/v1/admin/users/{id}/role", request: setRole.RoleRequest{}, response: response.Message{}},
+
+		{route: "POST /registration", request: registration.Request{}, response: response.Message{}},
+		{route: "POST /auth", request: auth.Request{}, response: auth.Response{}},
+		{route: "GET /auth/oidc/callback", response: response.Message{}},
+		{route: "GET /users/{username}", response: models.PublicProfile{}},
+		{route: "POST /protected/save-post", request: addPost.Request{}, response: response.Message{}},
+		{route: "GET /protected/next-posts", response: models.DbPost{}},
+		{route: "POST /protected/logout", response: response.Message{}},
+		{route: "GET /protected/logout", response: response.Message{}},
+		{route: "DELETE /protected/delete-post", request: deletePost.Request{}, response: response.Message{}},
+		{route: "POST /protected/password", request: changePassword.Request{}, response: changePassword.Response{}},
+		{route: "DELETE /protected/account", request: account.DeleteRequest{}, response: account.DeleteResponse{}},
+		{route: "POST /protected/account/restore", response: response.Message{}},
+		{route: "PATCH /protected/profile", request: profile.UpdateRequest{}, response: response.Message{}},
+		{route: "POST /protected/media", response: models.Media{}},
+		{route: "GET /protected/media/{id}", response: models.Media{}},
+		{route: "POST /protected/moderation/hide-post", request: hidePost.Request{}, response: response.Message{}},
+		{route: "POST /protected/admin/set-role", request: setRole.Request{}, response: response.Message{}},
+	}
+
+	for _, tc := range operations {
+		t.Run(tc.route, func(t *testing.T) {
+			method, path, _ := strings.Cut(tc.route, " ")
+			operation, ok := spec.Paths[path][strings.ToLower(method)]
+			if !ok {
+				t.Fatalf("operation is missing from openapi.json")
+			}
+
+			if tc.request != nil {
+				if operation.RequestBody == nil {
+					t.Fatalf("request body is not described")
+				}
+				schema := spec.resolve(operation.RequestBody.Content["application/json"].Schema)
+				compareSchema(t, "request", schema, reflect.TypeOf(tc.request), requestRequired)
+			}
+
+			schema, ok := successSchema(operation)
+			if !ok {
+				t.Fatalf("successful json response is not described")
+			}
+			data := schema.Properties["data"]
+			if data.Items != nil {
+				data = *data.Items
+			}
+			compareSchema(t, "response", spec.resolve(data), reflect.TypeOf(tc.response), responseRequired)
+		})
+	}
+
+	t.Run("page meta", func(t *testing.T) {
+		operation := spec.Paths["/api/v1/posts"]["get"]
+		schema, _ := successSchema(operation)
+		compareSchema(t, "meta", spec.resolve(schema.Properties["meta"]), reflect.TypeOf(response.Meta{}), responseRequired)
+	})
+}
+
+func TestSpecQueryParameters(t *testing.T) {
+	spec := loadSpec(t)
+
+	for _, path := range []string{"/api/v1/posts", "/protected/next-posts"} {
+		t.Run(path, func(t *testing.T) {
+			comparePagination(t, spec.Paths[path]["get"])
+		})
+	}
+}
+
+func comparePagination(t *testing.T, operation specOperation) {
+	t.Helper()
+	documented := make(map[string]bool)
+	for _, param := range operation.Parameters {
+		if param.In == "query" {
+			documented[param.Name] = param.Required
+		}
+	}
+
+	params := reflect.TypeOf(structs.PaginationParams{})
+	for i := 0; i < params.NumField(); i++ {
+		field := params.Field(i)
+		name := field.Tag.Get("form")
+		required, ok := documented[name]
+		if !ok {
+			t.Errorf("query parameter %q is not documented", name)
+			continue
+		}
+		if want := requestRequired(field); required != want {
+			t.Errorf("query parameter %q: required = %v in spec, %v in code", name, required, want)
+		}
+		delete(documented, name)
+	}
+	for name := range documented {
+		t.Errorf("documented query parameter %q is not bound by the handler", name)
+	}
+}
+
+func TestSpecRefsResolve(t *testing.T) {
+	var raw any
+	if err := json.Unmarshal(openapi.Spec, &raw); err != nil {
+		t.Fatalf("openapi.json is invalid: %v", err)
+	}
+	root := raw.(map[string]any)
+
+	var walk func(node any)
+	walk = func(node any) {
+		switch v := node.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok && lookupRef(root, ref) == nil {
+				t.Errorf("unresolved $ref %s", ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+}
+
+func TestDocsRoutes(t *testing.T) {
+	router := newTestRouter(t)
+
+	for path, contentType := range map[string]string{"/openapi.json": "application/json", "/docs": "text/html"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), contentType) {
+			t.Errorf("GET %s: status %d, content type %q", path, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestLegacyRoutesDeprecated(t *testing.T) {
+	spec := loadSpec(t)
+	router := newTestRouter(t)
+
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			if strings.HasPrefix(path, "/api/v1/") && operation.Deprecated {
+				t.Errorf("%s %s: /api/v1 operation must not be deprecated", strings.ToUpper(method), path)
+			}
+		}
+	}
+
+	// Запросы отклоняются до обращения к хранилищам: невалидное тело или нет токена
+	requests := []struct {
+		method     string
+		path       string
+		deprecated bool
+	}{
+		{method: http.MethodPost, path: "/registration", deprecated: true},
+		{method: http.MethodGet, path: "/protected/next-posts", deprecated: true},
+		{method: http.MethodGet, path: "/protected/logout", deprecated: true},
+		{method: http.MethodPost, path: "/api/v1/users", deprecated: false},
+		{method: http.MethodGet, path: "/api/v1/posts", deprecated: false},
+	}
+	for _, tc := range requests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader("{}")))
+
+		if got := w.Header().Get("Deprecation") != "" && w.Header().Get("Sunset") != ""; got != tc.deprecated {
+			t.Errorf("%s %s: deprecation headers present = %v, want %v", tc.method, tc.path, got, tc.deprecated)
+		}
+		if operation := spec.Paths[tc.path][strings.ToLower(tc.method)]; operation.Deprecated != tc.deprecated {
+			t.Errorf("%s %s: deprecated = %v in openapi.json, want %v", tc.method, tc.path, operation.Deprecated, tc.deprecated)
+		}
+	}
+}
+
+func (s specDocument) resolve(schema specSchema) specSchema {
+	if name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/"); ok {
+		return s.Components.Schemas[name]
+	}
+	return schema
+}
+
+func successSchema(operation specOperation) (specSchema, bool) {
+	for _, code := range []string{"200", "201", "202"} {
+		if content, ok := operation.Responses[code].Content["application/json"]; ok {
+			return content.Schema, true
+		}
+	}
+	return specSchema{}, false
+}
+
+// compareSchema сверяет поля и обязательность полей схемы со структурой
+func compareSchema(t *testing.T, kind string, schema specSchema, typ reflect.Type, isRequired func(reflect.StructField) bool) {
+	t.Helper()
+
+	var wantRequired []string
+	fields := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		fields[name] = true
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("%s field %q of %s is missing from the schema", kind, name, typ)
+		}
+		if isRequired(field) {
+			wantRequired = append(wantRequired, name)
+		}
+	}
+	for name := range schema.Properties {
+		if !fields[name] {
+			t.Errorf("%s schema property %q does not exist in %s", kind, name, typ)
+		}
+	}
+
+	gotRequired := slices.Clone(schema.Required)
+	slices.Sort(gotRequired)
+	slices.Sort(wantRequired)
+	if !slices.Equal(gotRequired, wantRequired) {
+		t.Errorf("%s schema for %s requires %v, code requires %v", kind, typ, gotRequired, wantRequired)
+	}
+}
+
+// requestRequired — поле обязательно, если в binding есть правило required
+func requestRequired(field reflect.StructField) bool {
+	return slices.Contains(strings.Split(field.Tag.Get("binding"), ","), "required")
+}
+
+// responseRequired — поле всегда присутствует в ответе, если у него нет omitempty
+func responseRequired(field reflect.StructField) bool {
+	_, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return !slices.Contains(strings.Split(opts, ","), "omitempty")
+}
+
+func lookupRef(root map[string]any, ref string) any {
+	var node any = root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil
+		}
+		node = m[part]
+	}
+	return node
+}
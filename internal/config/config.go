@@ -1,42 +1,314 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
+const (
+	EnvLocal = "local"
+	EnvDev   = "dev"
+	EnvProd  = "prod"
+)
+
 type Config struct {
-	Env                string `yaml:"env" env-required:"true"`
-	PostgresConnString string `yaml:"postgres_conn_string" env-required:"true"`
-	RedisAddress       string `yaml:"redis_address" env-required:"true"`
-	JWTSecret          string `yaml:"jwt_secret"`
-	HTTPServer         `yaml:"http_server"`
+	Env                string `yaml:"env" env:"ENV" env-default:"local"`
+	PostgresConnString string `yaml:"postgres_conn_string" env:"POSTGRES_CONN_STRING" redact:"true"`
+	RedisAddress       string `yaml:"redis_address" env:"REDIS_ADDRESS"`
+	JWTSecret          string `yaml:"jwt_secret" env:"JWT_SECRET" redact:"true"`
+	HTTPServer         `yaml:"http_server" env-prefix:"HTTP_SERVER_"`
+	PostgresPool       `yaml:"postgres_pool" env-prefix:"POSTGRES_POOL_"`
+	Redis              `yaml:"redis" env-prefix:"REDIS_"`
+	BruteForce         `yaml:"brute_force" env-prefix:"BRUTE_FORCE_"`
+	PasswordPolicy     `yaml:"password_policy" env-prefix:"PASSWORD_POLICY_"`
+	PasswordHashing    `yaml:"password_hashing" env-prefix:"PASSWORD_HASHING_"`
+	OIDC               `yaml:"oidc" env-prefix:"OIDC_"`
+	AccountDeletion    `yaml:"account_deletion" env-prefix:"ACCOUNT_DELETION_"`
+	Profiles           `yaml:"profiles" env-prefix:"PROFILES_"`
+	Media              `yaml:"media" env-prefix:"MEDIA_"`
+	Cookie             `yaml:"cookie" env-prefix:"COOKIE_"`
+	CSRF               `yaml:"csrf" env-prefix:"CSRF_"`
+	Migrations         `yaml:"migrations" env-prefix:"MIGRATIONS_"`
+	Health             `yaml:"health" env-prefix:"HEALTH_"`
+	Tracing            `yaml:"tracing" env-prefix:"TRACING_"`
+	LegacyAPI          `yaml:"legacy_api" env-prefix:"LEGACY_API_"`
+	RateLimit          `yaml:"rate_limit" env-prefix:"RATE_LIMIT_"`
+	Cache              `yaml:"cache" env-prefix:"CACHE_"`
+	CORS               `yaml:"cors" env-prefix:"CORS_"`
 }
 
 type HTTPServer struct {
-	Address  string `yaml:"address" env-required:"true"`
-	User     string `yaml:"user" env-required:"true"`
-	Password string `yaml:"password" env-required:"true"`
+	Address           string        `yaml:"address" env:"ADDRESS" env-default:":8080"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"READ_TIMEOUT" env-default:"30s"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"READ_HEADER_TIMEOUT" env-default:"5s"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"WRITE_TIMEOUT" env-default:"30s"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"IDLE_TIMEOUT" env-default:"60s"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"15s"`
+	// TrustedProxies — адреса или подсети прокси, которым доверяется X-Forwarded-For; пусто — IP берётся из соединения
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+type PostgresPool struct {
+	MaxConns        int32         `yaml:"max_conns" env:"MAX_CONNS" env-default:"10"`
+	MinConns        int32         `yaml:"min_conns" env:"MIN_CONNS" env-default:"0"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" env:"MAX_CONN_LIFETIME" env-default:"1h"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" env:"MAX_CONN_IDLE_TIME" env-default:"30m"`
+	QueryTimeout    time.Duration `yaml:"query_timeout" env:"QUERY_TIMEOUT" env-default:"5s"`
+}
+
+type Redis struct {
+	DB           int           `yaml:"db" env:"DB" env-default:"0"`
+	Password     string        `yaml:"password" env:"PASSWORD" redact:"true"`
+	PoolSize     int           `yaml:"pool_size" env:"POOL_SIZE" env-default:"10"`
+	DialTimeout  time.Duration `yaml:"dial_timeout" env:"DIAL_TIMEOUT" env-default:"5s"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"READ_TIMEOUT" env-default:"3s"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"WRITE_TIMEOUT" env-default:"3s"`
+}
+
+type BruteForce struct {
+	Window                 time.Duration `yaml:"window" env:"WINDOW" env-default:"15m"`
+	FreeAttempts           int64         `yaml:"free_attempts" env:"FREE_ATTEMPTS" env-default:"3"`
+	BaseDelay              time.Duration `yaml:"base_delay" env:"BASE_DELAY" env-default:"1s"`
+	MaxDelay               time.Duration `yaml:"max_delay" env:"MAX_DELAY" env-default:"1m"`
+	AccountLockoutAttempts int64         `yaml:"account_lockout_attempts" env:"ACCOUNT_LOCKOUT_ATTEMPTS" env-default:"10"`
+	IPLockoutAttempts      int64         `yaml:"ip_lockout_attempts" env:"IP_LOCKOUT_ATTEMPTS" env-default:"50"`
+	LockoutDuration        time.Duration `yaml:"lockout_duration" env:"LOCKOUT_DURATION" env-default:"15m"`
 }
 
-func MustLoad() *Config {
-	configPath := os.Getenv("CONFIG_PATH")
+type PasswordPolicy struct {
+	MinLength        int    `yaml:"min_length" env:"MIN_LENGTH" env-default:"8"`
+	MaxLength        int    `yaml:"max_length" env:"MAX_LENGTH" env-default:"72"`
+	BreachedListPath string `yaml:"breached_list_path" env:"BREACHED_LIST_PATH"`
+}
+
+type PasswordHashing struct {
+	Algorithm         string `yaml:"algorithm" env:"ALGORITHM" env-default:"argon2id"`
+	BcryptCost        int    `yaml:"bcrypt_cost" env:"BCRYPT_COST" env-default:"12"`
+	Argon2Memory      uint32 `yaml:"argon2_memory_kib" env:"ARGON2_MEMORY_KIB" env-default:"65536"`
+	Argon2Iterations  uint32 `yaml:"argon2_iterations" env:"ARGON2_ITERATIONS" env-default:"3"`
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism" env:"ARGON2_PARALLELISM" env-default:"2"`
+	Argon2SaltLength  uint32 `yaml:"argon2_salt_length" env:"ARGON2_SALT_LENGTH" env-default:"16"`
+	Argon2KeyLength   uint32 `yaml:"argon2_key_length" env:"ARGON2_KEY_LENGTH" env-default:"32"`
+}
+
+type OIDC struct {
+	Enabled           bool          `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	IssuerURL         string        `yaml:"issuer_url" env:"ISSUER_URL"`
+	ClientID          string        `yaml:"client_id" env:"CLIENT_ID"`
+	ClientSecret      string        `yaml:"client_secret" env:"CLIENT_SECRET" redact:"true"`
+	RedirectURL       string        `yaml:"redirect_url" env:"REDIRECT_URL"`
+	Scopes            []string      `yaml:"scopes" env:"SCOPES" env-default:"openid,email,profile"`
+	StateTTL          time.Duration `yaml:"state_ttl" env:"STATE_TTL" env-default:"10m"`
+	PostLoginRedirect string        `yaml:"post_login_redirect" env:"POST_LOGIN_REDIRECT"`
+}
+
+type AccountDeletion struct {
+	GracePeriod   time.Duration `yaml:"grace_period" env:"GRACE_PERIOD" env-default:"720h"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env:"PURGE_INTERVAL" env-default:"1h"`
+	// Очистка идёт пачками, у каждой пачки свой таймаут вместо postgres_pool.query_timeout
+	PurgeBatchSize int           `yaml:"purge_batch_size" env:"PURGE_BATCH_SIZE" env-default:"100"`
+	PurgeTimeout   time.Duration `yaml:"purge_timeout" env:"PURGE_TIMEOUT" env-default:"5m"`
+}
 
-	if configPath == "" {
-		log.Fatal("Config is nil")
+type Profiles struct {
+	UsernameRedirectTTL time.Duration `yaml:"username_redirect_ttl" env:"USERNAME_REDIRECT_TTL" env-default:"720h"`
+}
+
+type Media struct {
+	MaxUploadSize int64           `yaml:"max_upload_size" env:"MAX_UPLOAD_SIZE" env-default:"10485760"`
+	UserQuota     int64           `yaml:"user_quota" env:"USER_QUOTA" env-default:"104857600"`
+	AllowedTypes  []string        `yaml:"allowed_types" env:"ALLOWED_TYPES" env-default:"image/jpeg,image/png,image/gif,image/webp"`
+	Storage       string          `yaml:"storage" env:"STORAGE" env-default:"local"`
+	LocalDir      string          `yaml:"local_dir" env:"LOCAL_DIR" env-default:"./data/media"`
+	PublicBaseURL string          `yaml:"public_base_url" env:"PUBLIC_BASE_URL" env-default:"/media"`
+	S3            S3Storage       `yaml:"s3" env-prefix:"S3_"`
+	Processing    ImageProcessing `yaml:"processing" env-prefix:"PROCESSING_"`
+}
+
+type S3Storage struct {
+	Endpoint   string `yaml:"endpoint" env:"ENDPOINT"`
+	Region     string `yaml:"region" env:"REGION"`
+	Bucket     string `yaml:"bucket" env:"BUCKET"`
+	AccessKey  string `yaml:"access_key" env:"ACCESS_KEY" redact:"true"`
+	SecretKey  string `yaml:"secret_key" env:"SECRET_KEY" redact:"true"`
+	DisableSSL bool   `yaml:"disable_ssl" env:"DISABLE_SSL" env-default:"false"`
+}
+
+type ImageProcessing struct {
+	Workers       int           `yaml:"workers" env:"WORKERS" env-default:"4"`
+	QueueSize     int           `yaml:"queue_size" env:"QUEUE_SIZE" env-default:"100"`
+	SpoolDir      string        `yaml:"spool_dir" env:"SPOOL_DIR" env-default:"./data/spool"`
+	JPEGQuality   int           `yaml:"jpeg_quality" env:"JPEG_QUALITY" env-default:"85"`
+	ThumbnailSize int           `yaml:"thumbnail_size" env:"THUMBNAIL_SIZE" env-default:"150"`
+	MediumSize    int           `yaml:"medium_size" env:"MEDIUM_SIZE" env-default:"640"`
+	LargeSize     int           `yaml:"large_size" env:"LARGE_SIZE" env-default:"1280"`
+	MaxPixels     int64         `yaml:"max_pixels" env:"MAX_PIXELS" env-default:"40000000"`
+	StaleAfter    time.Duration `yaml:"stale_after" env:"STALE_AFTER" env-default:"1h"`
+}
+
+type Cookie struct {
+	Secure   bool   `yaml:"secure" env:"SECURE" env-default:"false"`
+	SameSite string `yaml:"same_site" env:"SAME_SITE" env-default:"lax"`
+	Domain   string `yaml:"domain" env:"DOMAIN"`
+}
+
+func (c Cookie) SameSiteMode() http.SameSite {
+	switch strings.ToLower(c.SameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
 	}
+}
 
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		log.Fatal("Config does not exist")
+// Булевы значения по умолчанию равны false: cleanenv не отличает явный false в файле от отсутствия значения
+type CSRF struct {
+	Disabled   bool   `yaml:"disabled" env:"DISABLED" env-default:"false"`
+	CookieName string `yaml:"cookie_name" env:"COOKIE_NAME" env-default:"csrf_token"`
+	HeaderName string `yaml:"header_name" env:"HEADER_NAME" env-default:"X-CSRF-Token"`
+}
+
+// CORS задаёт политику кросс-доменных запросов и выключен, пока AllowedOrigins пуст.
+// Источник задаётся как scheme://host[:port]; "https://*.example.com" разрешает любые поддомены,
+// "*" — любой источник без credentials
+type CORS struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins" env:"ALLOWED_ORIGINS"`
+	AllowedMethods   []string      `yaml:"allowed_methods" env:"ALLOWED_METHODS" env-default:"GET,POST,PUT,PATCH,DELETE"`
+	AllowedHeaders   []string      `yaml:"allowed_headers" env:"ALLOWED_HEADERS" env-default:"Authorization,Content-Type,X-Request-ID,If-None-Match,If-Modified-Since"`
+	ExposedHeaders   []string      `yaml:"exposed_headers" env:"EXPOSED_HEADERS" env-default:"X-Request-ID,ETag,Last-Modified,Location,Retry-After,RateLimit-Policy,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,Deprecation,Sunset,Link"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"ALLOW_CREDENTIALS" env-default:"false"`
+	MaxAge           time.Duration `yaml:"max_age" env:"MAX_AGE" env-default:"10m"`
+}
+
+type Migrations struct {
+	OnStart     bool          `yaml:"on_start" env:"ON_START" env-default:"false"`
+	LockTimeout time.Duration `yaml:"lock_timeout" env:"LOCK_TIMEOUT" env-default:"1m"`
+}
+
+type Health struct {
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"CHECK_TIMEOUT" env-default:"2s"`
+	// DrainDelay — пауза между снятием готовности и остановкой сервера
+	DrainDelay time.Duration `yaml:"drain_delay" env:"DRAIN_DELAY" env-default:"0s"`
+}
+
+type Tracing struct {
+	Exporter     string  `yaml:"exporter" env:"EXPORTER" env-default:"none"`
+	ServiceName  string  `yaml:"service_name" env:"SERVICE_NAME" env-default:"bloggery"`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"SAMPLE_RATIO" env-default:"1"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env:"OTLP_ENDPOINT"`
+	OTLPInsecure bool    `yaml:"otlp_insecure" env:"OTLP_INSECURE" env-default:"false"`
+}
+
+// LegacyAPI — даты для заголовков Deprecation и Sunset на маршрутах вне /api/v1
+type LegacyAPI struct {
+	DeprecatedAt time.Time `yaml:"deprecated_at" env:"DEPRECATED_AT" env-layout:"2006-01-02" env-default:"2026-10-19"`
+	Sunset       time.Time `yaml:"sunset" env:"SUNSET" env-layout:"2006-01-02" env-default:"2027-04-19"`
+}
+
+// RateLimit задаёт лимиты запросов для групп маршрутов: *_requests за *_period, 0 выключает лимит группы
+type RateLimit struct {
+	Disabled bool `yaml:"disabled" env:"DISABLED" env-default:"false"`
+	// FailClosed отклоняет запросы, пока Redis недоступен; по умолчанию они пропускаются без лимита
+	FailClosed    bool          `yaml:"fail_closed" env:"FAIL_CLOSED" env-default:"false"`
+	AuthRequests  int           `yaml:"auth_requests" env:"AUTH_REQUESTS" env-default:"10"`
+	AuthPeriod    time.Duration `yaml:"auth_period" env:"AUTH_PERIOD" env-default:"1m"`
+	WriteRequests int           `yaml:"write_requests" env:"WRITE_REQUESTS" env-default:"30"`
+	WritePeriod   time.Duration `yaml:"write_period" env:"WRITE_PERIOD" env-default:"1m"`
+	ReadRequests  int           `yaml:"read_requests" env:"READ_REQUESTS" env-default:"300"`
+	ReadPeriod    time.Duration `yaml:"read_period" env:"READ_PERIOD" env-default:"1m"`
+	// IP ограничивает защищённые маршруты до проверки токена, чтобы запросы без токена тоже учитывались
+	IPRequests int           `yaml:"ip_requests" env:"IP_REQUESTS" env-default:"600"`
+	IPPeriod   time.Duration `yaml:"ip_period" env:"IP_PERIOD" env-default:"1m"`
+}
+
+type Cache struct {
+	Disabled bool          `yaml:"disabled" env:"DISABLED" env-default:"false"`
+	TTL      time.Duration `yaml:"ttl" env:"TTL" env-default:"5m"`
+	// LocalSize — число записей в LRU внутри процесса, 0 выключает его.
+	// Другие инстансы не узнают об инвалидации, поэтому LocalTTL должен быть коротким
+	LocalSize int           `yaml:"local_size" env:"LOCAL_SIZE" env-default:"0"`
+	LocalTTL  time.Duration `yaml:"local_ttl" env:"LOCAL_TTL" env-default:"5s"`
+}
+
+// Load собирает конфигурацию с приоритетом:
+// значения по умолчанию < файл профиля < переменные окружения < флаги командной строки.
+//
+// Файл берётся из флага -config, переменной CONFIG_PATH или ./config/<profile>.yaml,
+// где профиль задаётся флагом -profile или переменной APP_PROFILE.
+func Load(args []string) (*Config, error) {
+	const op = "config.Load"
+
+	fs := flag.NewFlagSet("bloggery", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("CONFIG_PATH"), "path to config file")
+	profile := fs.String("profile", envOr("APP_PROFILE", EnvLocal), "config profile: local, dev or prod")
+
+	envByFlag := make(map[string]string)
+	for _, field := range envFields(&Config{}) {
+		fs.String(field.flagName(), "", fmt.Sprintf("overrides $%s", field.env))
+		envByFlag[field.flagName()] = field.env
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Флаги выставляются как переменные окружения, поэтому перекрывают и файл, и окружение
+	var setErr error
+	fs.Visit(func(f *flag.Flag) {
+		if env, ok := envByFlag[f.Name]; ok && setErr == nil {
+			setErr = os.Setenv(env, f.Value.String())
+		}
+	})
+	if setErr != nil {
+		return nil, fmt.Errorf("%s: %w", op, setErr)
+	}
+
+	path := *configPath
+	explicit := path != ""
+	if !explicit {
+		path = filepath.Join("config", *profile+".yaml")
 	}
 
-	var cfg Config
+	cfg := Config{Env: *profile}
+	if _, err := os.Stat(path); err == nil {
+		if err := cleanenv.ReadConfig(path, &cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	} else if explicit || !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: config %s: %w", op, path, err)
+	} else if err := cleanenv.ReadEnv(&cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
-	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
+	return &cfg, nil
+}
+
+func MustLoad(args []string) *Config {
+	cfg, err := Load(args)
+	if err != nil {
 		log.Fatalf("Failed to read config: %s", err)
 	}
+	return cfg
+}
 
-	return &cfg
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
 }
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+type envField struct {
+	env    string
+	path   []int
+	redact bool
+}
+
+func (f envField) flagName() string {
+	return flagName(f.env)
+}
+
+// flagName превращает POSTGRES_POOL_MAX_CONNS в postgres-pool-max-conns
+func flagName(env string) string {
+	return strings.ReplaceAll(strings.ToLower(env), "_", "-")
+}
+
+// envFields обходит структуру так же, как cleanenv: вложенные структуры добавляют env-prefix
+func envFields(cfg any) []envField {
+	var fields []envField
+	var walk func(t reflect.Type, prefix string, path []int)
+	walk = func(t reflect.Type, prefix string, path []int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fieldPath := append(append([]int{}, path...), i)
+
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+				walk(field.Type, prefix+field.Tag.Get("env-prefix"), fieldPath)
+				continue
+			}
+
+			env := field.Tag.Get("env")
+			if env == "" {
+				continue
+			}
+			fields = append(fields, envField{
+				env:    prefix + env,
+				path:   fieldPath,
+				redact: field.Tag.Get("redact") == "true",
+			})
+		}
+	}
+	walk(reflect.TypeOf(cfg).Elem(), "", nil)
+	return fields
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redactedValue = "[REDACTED]"
+
+// Redacted возвращает копию конфигурации, в которой секреты заменены заглушкой
+func (c Config) Redacted() Config {
+	value := reflect.ValueOf(&c).Elem()
+	for _, field := range envFields(&c) {
+		if !field.redact {
+			continue
+		}
+		fieldValue := value.FieldByIndex(field.path)
+		if fieldValue.Kind() == reflect.String && fieldValue.String() != "" {
+			fieldValue.SetString(redactedValue)
+		}
+	}
+	return c
+}
+
+func (c Config) Print(w io.Writer, redacted bool) error {
+	if redacted {
+		c = c.Redacted()
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
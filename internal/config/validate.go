@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Validate проверяет конфигурацию целиком и возвращает все найденные ошибки сразу
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(slices.Contains([]string{EnvLocal, EnvDev, EnvProd}, c.Env), "env must be one of local, dev, prod, got %q", c.Env)
+	check(c.PostgresConnString != "", "postgres_conn_string is required")
+	check(c.RedisAddress != "", "redis_address is required")
+	check(c.JWTSecret != "", "jwt_secret is required")
+	check(c.Env != EnvProd || len(c.JWTSecret) >= 32, "jwt_secret must be at least 32 bytes in prod")
+
+	check(c.HTTPServer.Address != "", "http_server.address is required")
+	check(c.HTTPServer.ReadTimeout > 0, "http_server.read_timeout must be positive")
+	check(c.HTTPServer.ReadHeaderTimeout > 0, "http_server.read_header_timeout must be positive")
+	check(c.HTTPServer.WriteTimeout > 0, "http_server.write_timeout must be positive")
+	check(c.HTTPServer.IdleTimeout > 0, "http_server.idle_timeout must be positive")
+	check(c.HTTPServer.ShutdownTimeout > 0, "http_server.shutdown_timeout must be positive")
+
+	check(c.PostgresPool.MaxConns > 0, "postgres_pool.max_conns must be positive")
+	check(c.PostgresPool.MinConns >= 0 && c.PostgresPool.MinConns <= c.PostgresPool.MaxConns, "postgres_pool.min_conns must be between 0 and max_conns")
+	check(c.PostgresPool.QueryTimeout > 0, "postgres_pool.query_timeout must be positive")
+	check(c.Redis.PoolSize > 0, "redis.pool_size must be positive")
+
+	check(c.BruteForce.Window > 0, "brute_force.window must be positive")
+	check(c.BruteForce.LockoutDuration > 0, "brute_force.lockout_duration must be positive")
+	check(c.BruteForce.BaseDelay <= c.BruteForce.MaxDelay, "brute_force.base_delay must not exceed max_delay")
+
+	check(c.PasswordPolicy.MinLength > 0, "password_policy.min_length must be positive")
+	check(c.PasswordPolicy.MaxLength >= c.PasswordPolicy.MinLength, "password_policy.max_length must not be less than min_length")
+	check(slices.Contains([]string{"bcrypt", "argon2id"}, c.PasswordHashing.Algorithm), "password_hashing.algorithm must be bcrypt or argon2id")
+
+	if c.OIDC.Enabled {
+		check(c.OIDC.IssuerURL != "", "oidc.issuer_url is required when oidc is enabled")
+		check(c.OIDC.ClientID != "", "oidc.client_id is required when oidc is enabled")
+		check(c.OIDC.RedirectURL != "", "oidc.redirect_url is required when oidc is enabled")
+	}
+
+	check(c.AccountDeletion.GracePeriod >= 0, "account_deletion.grace_period must not be negative")
+	check(c.AccountDeletion.PurgeInterval > 0, "account_deletion.purge_interval must be positive")
+	check(c.AccountDeletion.PurgeBatchSize > 0, "account_deletion.purge_batch_size must be positive")
+	check(c.AccountDeletion.PurgeTimeout > 0, "account_deletion.purge_timeout must be positive")
+
+	check(c.Media.MaxUploadSize > 0, "media.max_upload_size must be positive")
+	check(slices.Contains([]string{"local", "s3"}, c.Media.Storage), "media.storage must be local or s3")
+	if c.Media.Storage == "s3" {
+		check(c.Media.S3.Endpoint != "", "media.s3.endpoint is required for s3 storage")
+		check(c.Media.S3.Bucket != "", "media.s3.bucket is required for s3 storage")
+	}
+	check(c.Media.Processing.Workers > 0, "media.processing.workers must be positive")
+	check(c.Media.Processing.QueueSize >= 0, "media.processing.queue_size must not be negative")
+	check(c.Media.Processing.ThumbnailSize > 0, "media.processing.thumbnail_size must be positive")
+	check(c.Media.Processing.MediumSize >= 0, "media.processing.medium_size must not be negative")
+	check(c.Media.Processing.LargeSize >= 0, "media.processing.large_size must not be negative")
+	check(c.Media.Processing.StaleAfter > 0, "media.processing.stale_after must be positive")
+	check(c.Media.Processing.MaxPixels > 0, "media.processing.max_pixels must be positive")
+	check(c.Media.Processing.JPEGQuality >= 1 && c.Media.Processing.JPEGQuality <= 100, "media.processing.jpeg_quality must be between 1 and 100")
+
+	sameSite := strings.ToLower(c.Cookie.SameSite)
+	check(slices.Contains([]string{"lax", "strict", "none"}, sameSite), "cookie.same_site must be lax, strict or none")
+	check(sameSite != "none" || c.Cookie.Secure, "cookie.secure must be enabled when same_site is none")
+
+	check(c.Migrations.LockTimeout > 0, "migrations.lock_timeout must be positive")
+	check(c.Health.CheckTimeout > 0, "health.check_timeout must be positive")
+	check(c.Health.DrainDelay >= 0, "health.drain_delay must not be negative")
+
+	check(slices.Contains([]string{"none", "stdout", "otlp"}, c.Tracing.Exporter), "tracing.exporter must be none, stdout or otlp")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+	check(c.Tracing.ServiceName != "", "tracing.service_name is required")
+
+	check(c.LegacyAPI.Sunset.After(c.LegacyAPI.DeprecatedAt), "legacy_api.sunset must be after legacy_api.deprecated_at")
+
+	check(c.RateLimit.AuthRequests >= 0, "rate_limit.auth_requests must not be negative")
+	check(c.RateLimit.AuthRequests == 0 || c.RateLimit.AuthPeriod > 0, "rate_limit.auth_period must be positive")
+	check(c.RateLimit.WriteRequests >= 0, "rate_limit.write_requests must not be negative")
+	check(c.RateLimit.WriteRequests == 0 || c.RateLimit.WritePeriod > 0, "rate_limit.write_period must be positive")
+	check(c.RateLimit.ReadRequests >= 0, "rate_limit.read_requests must not be negative")
+	check(c.RateLimit.ReadRequests == 0 || c.RateLimit.ReadPeriod > 0, "rate_limit.read_period must be positive")
+	check(c.RateLimit.IPRequests >= 0, "rate_limit.ip_requests must not be negative")
+	check(c.RateLimit.IPRequests == 0 || c.RateLimit.IPPeriod > 0, "rate_limit.ip_period must be positive")
+
+	check(c.Cache.TTL > 0, "cache.ttl must be positive")
+	check(c.Cache.LocalSize >= 0, "cache.local_size must not be negative")
+	check(c.Cache.LocalSize == 0 || c.Cache.LocalTTL > 0, "cache.local_ttl must be positive")
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(validOrigin(origin), "cors.allowed_origins: invalid origin %q, want scheme://host[:port]", origin)
+		check(origin != "*" || !c.CORS.AllowCredentials, "cors.allowed_origins must not contain * when allow_credentials is enabled")
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age must not be negative")
+
+	check(c.CSRF.Disabled || (c.CSRF.CookieName != "" && c.CSRF.HeaderName != ""), "csrf.cookie_name and csrf.header_name are required when csrf is enabled")
+
+	return errors.Join(errs...)
+}
+
+// validOrigin допускает "*", точный источник и источник с "*." в начале хоста
+func validOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return false
+	}
+	host := strings.TrimPrefix(u.Host, "*.")
+	return host != "" && !strings.Contains(host, "*")
+}
@@ -0,0 +1,152 @@
+package account
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"new_service/internal/config"
+	"new_service/internal/lib/apperr"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/response"
+	"new_service/internal/lib/validation"
+	jwt_auth "new_service/pkg/auth"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ModeDelete    = "delete"
+	ModeAnonymize = "anonymize"
+)
+
+type DeleteRequest struct {
+	Password string `json:"password"`
+	Mode     string `json:"mode" binding:"required,oneof=delete anonymize"`
+}
+
+type DeleteResponse struct {
+	Message  string    `json:"message"`
+	DeleteAt time.Time `json:"delete_at"`
+}
+
+type AccountDeleter interface {
+	GetUserPasswordById(ctx context.Context, userId uuid.UUID) (string, error)
+	ScheduleUserDeletion(ctx context.Context, userId uuid.UUID, mode string, gracePeriod time.Duration) (time.Time, error)
+}
+
+type AccountRestorer interface {
+	CancelUserDeletion(ctx context.Context, userId uuid.UUID) error
+}
+
+type PasswordVerifier interface {
+	Verify(hash string, password string) (bool, bool, error)
+}
+
+type LoginGuard interface {
+	Check(ctx context.Context, ip string, account string) (time.Duration, error)
+	Fail(ctx context.Context, ip string, account string) error
+	Reset(ctx context.Context, account string) error
+}
+
+func NewDelete(log *slog.Logger, accountDeleter AccountDeleter, verifier PasswordVerifier, rdb *redis.Client, loginGuard LoginGuard, gracePeriod time.Duration, cookie config.Cookie) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		var req DeleteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Info("invalid request", sl.Error(err))
+			c.Error(validation.Error(err))
+			return
+		}
+
+		userId := c.GetString("user_id")
+		parsedUserId, err := uuid.Parse(userId)
+		if err != nil {
+			log.Info("invalid user id", slog.String("userId", userId))
+			c.Error(apperr.Unauthorized("invalid user id"))
+			return
+		}
+
+		passwordHash, err := accountDeleter.GetUserPasswordById(c.Request.Context(), parsedUserId)
+		if err != nil {
+			log.Info("failed to get user password", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get user password"))
+			return
+		}
+
+		// Аккаунты, созданные через OIDC, не имеют пароля
+		if passwordHash != "" {
+			// Перебор пароля через украденную сессию ограничиваем так же, как вход
+			account := "user:" + parsedUserId.String()
+			ip := c.ClientIP()
+			wait, err := loginGuard.Check(c.Request.Context(), ip, account)
+			if err != nil {
+				log.Error("failed to check password attempts", sl.Error(err))
+			}
+			if wait > 0 {
+				log.Info("too many password attempts")
+				c.Error(apperr.RateLimited("too many password attempts, try again later", wait))
+				return
+			}
+
+			ok, _, err := verifier.Verify(passwordHash, req.Password)
+			if err != nil {
+				log.Info("failed to verify password", sl.Error(err))
+			}
+			if !ok {
+				log.Info("invalid password")
+				if err := loginGuard.Fail(c.Request.Context(), ip, account); err != nil {
+					log.Error("failed to register password failure", sl.Error(err))
+				}
+				c.Error(apperr.Unauthorized("invalid password"))
+				return
+			}
+
+			if err := loginGuard.Reset(c.Request.Context(), account); err != nil {
+				log.Error("failed to reset password attempts", sl.Error(err))
+			}
+		}
+
+		deleteAt, err := accountDeleter.ScheduleUserDeletion(c.Request.Context(), parsedUserId, req.Mode, gracePeriod)
+		if err != nil {
+			log.Info("failed to schedule account deletion", sl.Error(err))
+			c.Error(apperr.From(err, "failed to schedule account deletion"))
+			return
+		}
+
+		if err := jwt_auth.RevokeUserSessions(c.Request.Context(), rdb, parsedUserId, ""); err != nil {
+			log.Error("failed to revoke sessions", sl.Error(err))
+		}
+		jwt_auth.ClearTokenCookie(c, cookie)
+
+		log.Info("account deletion scheduled", slog.String("user_id", userId), slog.String("mode", req.Mode))
+		response.JSON(c, http.StatusAccepted, DeleteResponse{
+			Message:  "account deletion scheduled",
+			DeleteAt: deleteAt,
+		})
+	}
+}
+
+func NewRestore(log *slog.Logger, accountRestorer AccountRestorer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		userId := c.GetString("user_id")
+		parsedUserId, err := uuid.Parse(userId)
+		if err != nil {
+			log.Info("invalid user id", slog.String("userId", userId))
+			c.Error(apperr.Unauthorized("invalid user id"))
+			return
+		}
+
+		if err := accountRestorer.CancelUserDeletion(c.Request.Context(), parsedUserId); err != nil {
+			log.Info("failed to cancel account deletion", sl.Error(err))
+			c.Error(apperr.From(err, "failed to cancel account deletion"))
+			return
+		}
+
+		log.Info("account deletion cancelled", slog.String("user_id", userId))
+		response.Text(c, http.StatusOK, "account deletion cancelled")
+	}
+}
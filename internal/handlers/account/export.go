@@ -0,0 +1,176 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"new_service/internal/lib/apperr"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const exportFormatVersion = 1
+
+type DataExporter interface {
+	GetUser(ctx context.Context, userId uuid.UUID) (models.User, error)
+	GetUserIdentities(ctx context.Context, userId uuid.UUID) ([]models.ExternalIdentity, error)
+	GetAllUserPosts(ctx context.Context, userId uuid.UUID) ([]models.DbPost, error)
+}
+
+type profileExport struct {
+	models.User
+	Identities []models.ExternalIdentity `json:"identities"`
+}
+
+type metadataExport struct {
+	FormatVersion int       `json:"format_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	UserId        uuid.UUID `json:"user_id"`
+	PostsCount    int       `json:"posts_count"`
+	Files         []string  `json:"files"`
+}
+
+func NewExport(log *slog.Logger, dataExporter DataExporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		userId := c.GetString("user_id")
+		parsedUserId, err := uuid.Parse(userId)
+		if err != nil {
+			log.Info("invalid user id", slog.String("userId", userId))
+			c.Error(apperr.Unauthorized("invalid user id"))
+			return
+		}
+
+		user, err := dataExporter.GetUser(c.Request.Context(), parsedUserId)
+		if err != nil {
+			log.Info("failed to get user", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get user"))
+			return
+		}
+
+		identities, err := dataExporter.GetUserIdentities(c.Request.Context(), parsedUserId)
+		if err != nil {
+			log.Info("failed to get user identities", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get user identities"))
+			return
+		}
+
+		posts, err := dataExporter.GetAllUserPosts(c.Request.Context(), parsedUserId)
+		if err != nil {
+			log.Info("failed to get user posts", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get user posts"))
+			return
+		}
+
+		now := time.Now().UTC()
+		archive, err := buildExportArchive(profileExport{User: user, Identities: identities}, posts, now)
+		if err != nil {
+			log.Error("failed to build export archive", sl.Error(err))
+			c.Error(apperr.From(err, "failed to build export archive"))
+			return
+		}
+
+		log.Info("account data exported", slog.String("user_id", userId))
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="bloggery-export-%s.zip"`, now.Format("2006-01-02")))
+		c.Data(http.StatusOK, "application/zip", archive)
+	}
+}
+
+func buildExportArchive(profile profileExport, posts []models.DbPost, exportedAt time.Time) ([]byte, error) {
+	files := []struct {
+		name string
+		data func() ([]byte, error)
+	}{
+		{"profile.json", func() ([]byte, error) { return json.MarshalIndent(profile, "", "  ") }},
+		{"profile.md", func() ([]byte, error) { return []byte(profileMarkdown(profile)), nil }},
+		{"posts.json", func() ([]byte, error) { return json.MarshalIndent(posts, "", "  ") }},
+		{"posts.md", func() ([]byte, error) { return []byte(postsMarkdown(posts)), nil }},
+	}
+
+	metadata := metadataExport{
+		FormatVersion: exportFormatVersion,
+		ExportedAt:    exportedAt,
+		UserId:        profile.UserId,
+		PostsCount:    len(posts),
+		Files:         []string{"metadata.json"},
+	}
+	for _, file := range files {
+		metadata.Files = append(metadata.Files, file.name)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	metadataJson, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeZipFile(zw, "metadata.json", metadataJson, exportedAt); err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		data, err := file.data()
+		if err != nil {
+			return nil, err
+		}
+		if err := writeZipFile(zw, file.name, data, exportedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte, modified time.Time) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func profileMarkdown(profile profileExport) string {
+	var b strings.Builder
+	b.WriteString("# Profile\n\n")
+	fmt.Fprintf(&b, "- User ID: %s\n", profile.UserId)
+	fmt.Fprintf(&b, "- Email: %s\n", profile.Email)
+	if profile.Username != nil {
+		fmt.Fprintf(&b, "- Username: %s\n", *profile.Username)
+	}
+	fmt.Fprintf(&b, "- Role: %s\n", profile.Role)
+	fmt.Fprintf(&b, "- Created at: %s\n", profile.CreatedAt.Format(time.RFC3339))
+
+	if len(profile.Identities) > 0 {
+		b.WriteString("\n## Linked accounts\n\n")
+		for _, identity := range profile.Identities {
+			fmt.Fprintf(&b, "- %s (%s)\n", identity.Issuer, identity.Subject)
+		}
+	}
+	return b.String()
+}
+
+func postsMarkdown(posts []models.DbPost) string {
+	var b strings.Builder
+	b.WriteString("# Posts\n")
+	for _, post := range posts {
+		fmt.Fprintf(&b, "\n## %s\n\n", post.Title)
+		fmt.Fprintf(&b, "_Created at %s, updated at %s_\n\n", post.CreatedAt.Format(time.RFC3339), post.UpdatedAt.Format(time.RFC3339))
+		b.WriteString(post.Content)
+		b.WriteString("\n\n---\n")
+	}
+	return b.String()
+}
@@ -1,9 +1,14 @@
 package addPost
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"new_service/internal/lib/apperr"
 	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/metrics"
+	"new_service/internal/lib/response"
+	"new_service/internal/lib/validation"
 	"new_service/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -11,20 +16,21 @@ import (
 )
 
 type Request struct {
-	Title   string `json:"title" env-required:"true"`
-	Content string `json:"content"`
+	Title   string `json:"title" binding:"required,max=256"`
+	Content string `json:"content" binding:"required,min=10"`
 }
 
 type PostSaver interface {
-	SavePost(*models.Post) error
+	SavePost(context.Context, *models.Post) error
 }
 
 func New(log *slog.Logger, postSaver PostSaver) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
 		var req Request
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			c.Error(validation.Error(err))
 			return
 		}
 
@@ -32,7 +38,7 @@ func New(log *slog.Logger, postSaver PostSaver) gin.HandlerFunc {
 		parseUserId, err := uuid.Parse(userId)
 		if err != nil {
 			log.Info("invalid user id", slog.String("userId", userId))
-			c.JSON(http.StatusBadRequest, "invalid user id")
+			c.Error(apperr.Unauthorized("invalid user id"))
 			return
 		}
 
@@ -42,14 +48,15 @@ func New(log *slog.Logger, postSaver PostSaver) gin.HandlerFunc {
 			Content: req.Content,
 		}
 
-		err = postSaver.SavePost(&post_to_save)
+		err = postSaver.SavePost(c.Request.Context(), &post_to_save)
 		if err != nil {
 			log.Info("failed to save post", sl.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"message": "failed to save post"})
+			c.Error(apperr.From(err, "failed to save post"))
 			return
 		}
 
 		log.Info("post saved successfully")
-		c.JSON(http.StatusOK, gin.H{"message": "post saved successfully"})
+		metrics.PostsCreated.Inc()
+		response.Text(c, http.StatusOK, "post saved successfully")
 	}
 }
@@ -1,99 +1,164 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"log/slog"
-	"net/http"
 	"new_service/internal/config"
+	"new_service/internal/lib/apperr"
 	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/metrics"
+	"new_service/internal/lib/response"
+	"new_service/internal/lib/validation"
 	custom_errors "new_service/internal/repository"
 	jwt_auth "new_service/pkg/auth"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type Request struct {
-	Email    string `json:"email"`
-	Username string `json:"username"`
-	Password string `json:"password" env-required:"true"`
+	Email    string `json:"email" binding:"required_without=Username,omitempty,email"`
+	Username string `json:"username" binding:"required_without=Email"`
+	Password string `json:"password" binding:"required"`
+	// Bearer - клиент передаёт токен в заголовке Authorization и просит вернуть его в теле ответа
+	Bearer bool `json:"bearer"`
+}
+
+type Response struct {
+	Message string `json:"message"`
+	Token   string `json:"token,omitempty"`
 }
 
 type UserGetter interface {
-	GetUserPasswordByEmail(email string) (string, uuid.UUID, error)
-	GetUserPasswordByUsername(username string) (string, uuid.UUID, error)
+	GetUserPasswordByEmail(ctx context.Context, email string) (string, uuid.UUID, error)
+	GetUserPasswordByUsername(ctx context.Context, username string) (string, uuid.UUID, error)
+	GetUserRole(ctx context.Context, userId uuid.UUID) (string, error)
+	UpdateUserPassword(ctx context.Context, userId uuid.UUID, password string) error
+}
+
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash string, password string) (bool, bool, error)
 }
 
-func New(log *slog.Logger, cfg *config.Config, userGetter UserGetter) gin.HandlerFunc {
+type LoginGuard interface {
+	Check(ctx context.Context, ip string, account string) (time.Duration, error)
+	Fail(ctx context.Context, ip string, account string) error
+	Reset(ctx context.Context, account string) error
+}
+
+func New(log *slog.Logger, cfg *config.Config, userGetter UserGetter, hasher PasswordHasher, loginGuard LoginGuard) gin.HandlerFunc {
+	// dummyHash сравнивается с паролем, когда пользователь не найден,
+	// чтобы время ответа не выдавало существование аккаунта
+	dummyHash, err := hasher.Hash("dummy password for timing")
+	if err != nil {
+		log.Error("failed to create dummy hash", sl.Error(err))
+	}
+
 	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
 		var req Request
 
 		if err := c.ShouldBindJSON(&req); err != nil {
 			log.Info("failed to decode request body", sl.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			c.Error(validation.Error(err))
 			return
 		}
 
-		if req.Email == "" && req.Username == "" {
-			log.Info("invalid request: no email or username")
-			c.JSON(http.StatusBadRequest, gin.H{"message": "email or username must be providen"})
+		account := req.Email
+		if account == "" {
+			account = req.Username
+		}
+		ip := c.ClientIP()
+
+		wait, err := loginGuard.Check(c.Request.Context(), ip, account)
+		if err != nil {
+			log.Error("failed to check login attempts", sl.Error(err))
+		}
+		if wait > 0 {
+			log.Info("too many login attempts", slog.String("ip", ip))
+			c.Error(apperr.RateLimited("too many login attempts, try again later", wait))
 			return
 		}
 
-		var err error
 		var user_id uuid.UUID
+		var needsRehash bool
 		if req.Email != "" {
-			user_id, err = CheckUserPassword(userGetter.GetUserPasswordByEmail, req.Email, req.Password)
+			user_id, needsRehash, err = CheckUserPassword(c.Request.Context(), userGetter.GetUserPasswordByEmail, hasher, dummyHash, req.Email, req.Password)
 		} else {
-			user_id, err = CheckUserPassword(userGetter.GetUserPasswordByUsername, req.Username, req.Password)
+			user_id, needsRehash, err = CheckUserPassword(c.Request.Context(), userGetter.GetUserPasswordByUsername, hasher, dummyHash, req.Username, req.Password)
 		}
 		if err != nil {
-			if errors.Is(err, custom_errors.ErrUserDoesNotExist) {
-				log.Info("No user with such email and username")
-				c.JSON(http.StatusNotFound, gin.H{"message": "No user with such email and username"})
-				return
-			}
-			if errors.Is(err, custom_errors.ErrInvalidPassword) {
-				log.Info("Invalid password")
-				c.JSON(http.StatusBadRequest, gin.H{"message": "invalid password"})
+			if errors.Is(err, custom_errors.ErrUserDoesNotExist) || errors.Is(err, custom_errors.ErrInvalidPassword) {
+				log.Info("invalid credentials", sl.Error(err))
+				metrics.Logins.WithLabelValues("password", "failure").Inc()
+				if err := loginGuard.Fail(c.Request.Context(), ip, account); err != nil {
+					log.Error("failed to register login failure", sl.Error(err))
+				}
+				c.Error(apperr.Unauthorized("invalid credentials"))
 				return
 			}
 
 			log.Info("failed to check password", sl.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"message": "failed to check password"})
+			c.Error(apperr.From(err, "failed to check password"))
 			return
 		}
 
-		jwt_token, err := jwt_auth.MakeJwtToken(cfg.JWTSecret, user_id)
+		if err := loginGuard.Reset(c.Request.Context(), account); err != nil {
+			log.Error("failed to reset login attempts", sl.Error(err))
+		}
+
+		if needsRehash {
+			if err := userGetter.UpdateUserPassword(c.Request.Context(), user_id, req.Password); err != nil {
+				log.Error("failed to upgrade password hash", sl.Error(err))
+			} else {
+				log.Info("password hash upgraded")
+			}
+		}
+
+		role, err := userGetter.GetUserRole(c.Request.Context(), user_id)
+		if err != nil {
+			log.Info("failed to get user role", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get user role"))
+			return
+		}
+
+		jwt_token, err := jwt_auth.MakeJwtToken(cfg.JWTSecret, user_id, role)
 		if err != nil {
 			log.Info("failed to create jwt", sl.Error(err))
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "failed to create jwt"})
+			c.Error(apperr.From(err, "failed to create jwt"))
 			return
 		}
 
-		c.SetCookie(
-			"jwt_token",
-			jwt_token,
-			60*60*24,
-			"/",
-			"",
-			false,
-			true,
-		)
+		jwt_auth.SetTokenCookie(c, cfg.Cookie, jwt_token)
+
+		// Браузеру достаточно httpOnly cookie, токен в теле отдаём только по явному запросу
+		resp := Response{Message: "logged in successfully"}
+		if req.Bearer {
+			resp.Token = jwt_token
+		}
+
+		log.Info("logged in successfully")
+		metrics.Logins.WithLabelValues("password", "success").Inc()
+		response.OK(c, resp)
 	}
 }
 
-func CheckUserPassword(GetUserPassword func(string) (string, uuid.UUID, error), email_or_username string, request_password string) (uuid.UUID, error) {
-	user_password, user_id, err := GetUserPassword(email_or_username)
+func CheckUserPassword(ctx context.Context, GetUserPassword func(context.Context, string) (string, uuid.UUID, error), hasher PasswordHasher, dummyHash string, email_or_username string, request_password string) (uuid.UUID, bool, error) {
+	user_password, user_id, err := GetUserPassword(ctx, email_or_username)
 	if err != nil {
-		return user_id, err
+		if errors.Is(err, custom_errors.ErrUserDoesNotExist) {
+			_, _, _ = hasher.Verify(dummyHash, request_password)
+		}
+		return user_id, false, err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user_password), []byte(request_password))
-	if err != nil {
-		return user_id, custom_errors.ErrInvalidPassword
+	ok, needsRehash, err := hasher.Verify(user_password, request_password)
+	if err != nil || !ok {
+		return user_id, false, custom_errors.ErrInvalidPassword
 	}
 
-	return user_id, nil
+	return user_id, needsRehash, nil
 }
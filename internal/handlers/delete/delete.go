@@ -1,67 +1,91 @@
 package deletePost
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
+	"new_service/internal/lib/apperr"
 	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/response"
+	"new_service/internal/lib/validation"
 	"new_service/internal/models"
+	jwt_auth "new_service/pkg/auth"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type Request struct {
-	PostId uuid.UUID `json:"post_id" env-required:"true"`
+	PostId uuid.UUID `json:"post_id" binding:"required"`
 }
 
 type PostDeleter interface {
-	DeletePost(post_id uuid.UUID) error
-	GetPost(post_id uuid.UUID) (models.DbPost, error)
+	DeletePost(ctx context.Context, post_id uuid.UUID) error
+	GetPost(ctx context.Context, post_id uuid.UUID) (models.DbPost, error)
 }
 
 func New(log *slog.Logger, postDeleter PostDeleter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req Request
-		if err := c.ShouldBindJSON(&req); err != nil {
+		log := sl.FromContext(c.Request.Context(), log)
+		postId, err := requestPostId(c)
+		if err != nil {
 			log.Info("invalid request", sl.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid request"})
+			c.Error(err)
 			return
 		}
 
-		postToDelete, err := postDeleter.GetPost(req.PostId)
+		postToDelete, err := postDeleter.GetPost(c.Request.Context(), postId)
 		if err != nil {
 			log.Info("failed to get post", sl.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to get post"})
+			c.Error(apperr.From(err, "failed to get post"))
 			return
 		}
 
 		providedUserId, ok := c.Get("user_id")
 		if !ok {
 			log.Info("no user id")
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "no user id"})
+			c.Error(apperr.Unauthorized("no user id"))
 			return
 		}
 		parsedProvidedUserId, err := uuid.Parse(providedUserId.(string))
 		if err != nil {
 			log.Info("invalid user id", sl.Error(err))
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid user id"})
+			c.Error(apperr.Unauthorized("invalid user id"))
 			return
 		}
-		if postToDelete.UserId != parsedProvidedUserId {
+		isModerator := jwt_auth.HasRole(c, jwt_auth.RoleModerator, jwt_auth.RoleAdmin)
+		if postToDelete.UserId != parsedProvidedUserId && !isModerator {
 			log.Info("not user's post, forbidden")
-			c.JSON(http.StatusForbidden, gin.H{"message": "not your post"})
+			c.Error(apperr.Forbidden("not your post"))
 			return
 		}
 		log.Info("post author checked successfully")
 
-		err = postDeleter.DeletePost(req.PostId)
+		err = postDeleter.DeletePost(c.Request.Context(), postId)
 		if err != nil {
 			log.Info("failed to delete post", sl.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"message": "failed to delete post"})
+			c.Error(apperr.From(err, "failed to delete post"))
 			return
 		}
 
 		log.Info("deleted post successfully")
-		c.JSON(http.StatusOK, gin.H{"message": "deleted post successfully"})
+		response.Text(c, http.StatusOK, "deleted post successfully")
+	}
+}
+
+// requestPostId берёт идентификатор из пути /posts/:id, а для устаревшего маршрута — из тела запроса
+func requestPostId(c *gin.Context) (uuid.UUID, error) {
+	if id := c.Param("id"); id != "" {
+		postId, err := uuid.Parse(id)
+		if err != nil {
+			return uuid.Nil, apperr.Invalid("invalid post id")
+		}
+		return postId, nil
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return uuid.Nil, validation.Error(err)
 	}
+	return req.PostId, nil
 }
@@ -1,47 +1,84 @@
 package getNextPosts
 
 import (
+	"context"
 	"log/slog"
-	"net/http"
 	"new_service/internal/handlers/structs"
+	"new_service/internal/lib/apperr"
+	"new_service/internal/lib/httpcache"
 	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/response"
+	"new_service/internal/lib/validation"
 	"new_service/internal/models"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type PostsGetter interface {
-	GetNextPosts(userId uuid.UUID, paginarionParams structs.PaginationParams) ([]models.DbPost, error)
+	GetNextPosts(ctx context.Context, userId uuid.UUID, paginarionParams structs.PaginationParams) ([]models.DbPost, error)
 }
 
 func New(log *slog.Logger, postsGetter PostsGetter) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
 
 		userId := c.GetString("user_id")
 
 		parsedUserId, err := uuid.Parse(c.GetString("user_id"))
 		if err != nil {
 			log.Info("invalid user id", slog.String("userId", userId))
-			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid user id"})
+			c.Error(apperr.Unauthorized("invalid user id"))
 			return
 		}
 
 		paginationParamas := structs.PaginationParams{}
 		if err := c.ShouldBindQuery(&paginationParamas); err != nil {
 			log.Info("invalid query params")
-			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid query params"})
+			c.Error(validation.Error(err))
 			return
 		}
 
-		posts, err := postsGetter.GetNextPosts(parsedUserId, paginationParamas)
+		posts, err := postsGetter.GetNextPosts(c.Request.Context(), parsedUserId, paginationParamas)
 		if err != nil {
 			log.Info("failed to get posts", sl.Error(err))
-			c.JSON(http.StatusBadRequest, gin.H{"message": "failed to get posts"})
+			c.Error(apperr.From(err, "failed to get posts"))
+			return
+		}
+
+		// Last-Modified не выставляется: по нему клиент не узнает об удалённых постах
+		meta := pageMeta(posts, paginationParamas)
+		if httpcache.NotModified(c, pageETag(posts, meta), time.Time{}) {
 			return
 		}
 
 		log.Info("Next posts got successdully")
-		c.JSON(http.StatusOK, posts)
+		response.Page(c, posts, meta)
+	}
+}
+
+// pageMeta отдаёт курсор следующей страницы, только если текущая заполнена целиком
+func pageMeta(posts []models.DbPost, params structs.PaginationParams) response.Meta {
+	meta := response.Meta{Limit: params.Limit, Count: len(posts)}
+	if len(posts) == 0 || len(posts) < params.Limit {
+		return meta
+	}
+
+	// В обратном режиме посты тоже отсортированы от новых к старым, поэтому курсор — самый новый
+	next := posts[len(posts)-1].CreatedAt
+	if params.Reverse {
+		next = posts[0].CreatedAt
+	}
+	meta.NextCursor = &next
+	return meta
+}
+
+// pageETag зависит от состава страницы и версий постов в ней
+func pageETag(posts []models.DbPost, meta response.Meta) string {
+	versions := make([]any, 0, 2*len(posts)+1)
+	for _, post := range posts {
+		versions = append(versions, post.PostId, post.UpdatedAt)
 	}
+	return httpcache.ETag(append(versions, meta)...)
 }
@@ -0,0 +1,85 @@
+package getPost
+
+import (
+	"context"
+	"log/slog"
+	"new_service/internal/lib/apperr"
+	"new_service/internal/lib/httpcache"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/response"
+	"new_service/internal/models"
+	custom_errors "new_service/internal/repository"
+	jwt_auth "new_service/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PostGetter interface {
+	GetPost(ctx context.Context, post_id uuid.UUID) (models.DbPost, error)
+}
+
+func New(log *slog.Logger, postGetter PostGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		postId, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			log.Info("invalid post id", slog.String("post_id", c.Param("id")))
+			c.Error(apperr.Invalid("invalid post id"))
+			return
+		}
+
+		post, err := postGetter.GetPost(c.Request.Context(), postId)
+		if err != nil {
+			log.Info("failed to get post", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get post"))
+			return
+		}
+
+		// Скрытый пост видят только автор и модераторы
+		isModerator := jwt_auth.HasRole(c, jwt_auth.RoleModerator, jwt_auth.RoleAdmin)
+		if post.Hidden && post.UserId.String() != c.GetString("user_id") && !isModerator {
+			c.Error(custom_errors.ErrPostDoesNotExist)
+			return
+		}
+
+		// Cache-Control остаётся private от группы: ответ зависит от того, кто спрашивает,
+		// а скрытые посты не должны попадать в общий кэш. Для общих кэшей есть NewPublic
+		if httpcache.NotModified(c, httpcache.ETag(post.PostId, post.UserId, post.UpdatedAt), post.UpdatedAt) {
+			return
+		}
+
+		response.OK(c, post)
+	}
+}
+
+// NewPublic отдаёт опубликованный пост без авторизации. Скрытый пост не отличается от
+// несуществующего, поэтому ответ одинаков для всех и его можно хранить в общем кэше
+func NewPublic(log *slog.Logger, postGetter PostGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		postId, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			log.Info("invalid post id", slog.String("post_id", c.Param("id")))
+			c.Error(apperr.Invalid("invalid post id"))
+			return
+		}
+
+		post, err := postGetter.GetPost(c.Request.Context(), postId)
+		if err != nil {
+			log.Info("failed to get post", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get post"))
+			return
+		}
+		if post.Hidden {
+			c.Error(custom_errors.ErrPostDoesNotExist)
+			return
+		}
+
+		if httpcache.NotModified(c, httpcache.ETag(post.PostId, post.UserId, post.UpdatedAt), post.UpdatedAt) {
+			return
+		}
+
+		response.OK(c, post)
+	}
+}
@@ -0,0 +1,74 @@
+package getPost
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"new_service/internal/lib/apperr"
+	"new_service/internal/lib/httpcache"
+	"new_service/internal/models"
+	custom_errors "new_service/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type fakePosts map[uuid.UUID]models.DbPost
+
+func (f fakePosts) GetPost(ctx context.Context, postId uuid.UUID) (models.DbPost, error) {
+	post, ok := f[postId]
+	if !ok {
+		return models.DbPost{}, custom_errors.ErrPostDoesNotExist
+	}
+	return post, nil
+}
+
+func TestPublicPost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	updated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	published := models.DbPost{PostId: uuid.New(), UserId: uuid.New(), UpdatedAt: updated}
+	hidden := models.DbPost{PostId: uuid.New(), UserId: uuid.New(), UpdatedAt: updated, Hidden: true}
+	etag := httpcache.ETag(published.PostId, published.UserId, published.UpdatedAt)
+
+	router := gin.New()
+	router.Use(apperr.Middleware(slog.New(slog.NewTextHandler(io.Discard, nil))), httpcache.CacheControl(httpcache.Public))
+	router.GET("/posts/:id", NewPublic(slog.New(slog.NewTextHandler(io.Discard, nil)), fakePosts{
+		published.PostId: published,
+		hidden.PostId:    hidden,
+	}))
+
+	tests := []struct {
+		name        string
+		id          string
+		ifNoneMatch string
+		wantStatus  int
+		wantCache   string
+	}{
+		{"published", published.PostId.String(), "", http.StatusOK, httpcache.Public},
+		{"not modified", published.PostId.String(), etag, http.StatusNotModified, httpcache.Public},
+		{"hidden", hidden.PostId.String(), "", http.StatusNotFound, httpcache.NoStore},
+		{"missing", uuid.NewString(), "", http.StatusNotFound, httpcache.NoStore},
+		{"invalid id", "not-a-uuid", "", http.StatusBadRequest, httpcache.NoStore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/posts/"+tt.id, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+		})
+	}
+}
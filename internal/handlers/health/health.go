@@ -0,0 +1,81 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	sl "new_service/internal/lib/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Check проверяет одну зависимость сервиса
+type Check func(ctx context.Context) error
+
+// Readiness снимается в main в начале graceful shutdown, чтобы балансировщик перестал слать трафик
+type Readiness struct {
+	draining atomic.Bool
+}
+
+func (r *Readiness) Drain() {
+	r.draining.Store(true)
+}
+
+func (r *Readiness) Draining() bool {
+	return r.draining.Load()
+}
+
+// NewLive отвечает, пока процесс жив и обрабатывает запросы
+func NewLive() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// NewReady опрашивает все зависимости параллельно, каждую со своим таймаутом
+func NewReady(log *slog.Logger, readiness *Readiness, timeout time.Duration, checks map[string]Check) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		if readiness.Draining() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+			return
+		}
+
+		// Текст ошибки только пишется в лог: /readyz открыт без авторизации
+		results := make(map[string]string, len(checks))
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for name, check := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+				defer cancel()
+
+				result := "ok"
+				if err := check(ctx); err != nil {
+					log.Warn("dependency is not ready", slog.String("dependency", name), sl.Error(err))
+					result = "unavailable"
+				}
+
+				mu.Lock()
+				results[name] = result
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		status, code := "ok", http.StatusOK
+		for _, result := range results {
+			if result != "ok" {
+				status, code = "unavailable", http.StatusServiceUnavailable
+			}
+		}
+
+		c.JSON(code, gin.H{"status": status, "checks": results})
+	}
+}
@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadyHidesErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	router := gin.New()
+	router.GET("/readyz", NewReady(log, &Readiness{}, time.Second, map[string]Check{
+		"postgres": func(ctx context.Context) error { return nil },
+		"redis":    func(ctx context.Context) error { return errors.New("dial tcp 10.0.0.5:6379: connection refused") },
+	}))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "10.0.0.5") {
+		t.Errorf("response leaks the error: %s", rec.Body)
+	}
+
+	var body struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if body.Status != "unavailable" || body.Checks["postgres"] != "ok" || body.Checks["redis"] != "unavailable" {
+		t.Errorf("unexpected response %+v", body)
+	}
+}
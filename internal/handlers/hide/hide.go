@@ -0,0 +1,75 @@
+package hidePost
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"new_service/internal/lib/apperr"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/response"
+	"new_service/internal/lib/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type Request struct {
+	PostId uuid.UUID `json:"post_id" binding:"required"`
+	Hidden bool      `json:"hidden"`
+}
+
+// VisibilityRequest — тело PUT .../posts/:id/visibility
+type VisibilityRequest struct {
+	Hidden *bool `json:"hidden" binding:"required"`
+}
+
+type PostHider interface {
+	SetPostHidden(ctx context.Context, post_id uuid.UUID, hidden bool) error
+}
+
+func New(log *slog.Logger, postHider PostHider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		postId, hidden, err := bindRequest(c)
+		if err != nil {
+			log.Info("invalid request", sl.Error(err))
+			c.Error(err)
+			return
+		}
+
+		err = postHider.SetPostHidden(c.Request.Context(), postId, hidden)
+		if err != nil {
+			log.Info("failed to change post visibility", sl.Error(err))
+			c.Error(apperr.From(err, "failed to change post visibility"))
+			return
+		}
+
+		log.Info("post visibility changed",
+			slog.String("post_id", postId.String()),
+			slog.Bool("hidden", hidden),
+			slog.String("moderator_id", c.GetString("user_id")),
+		)
+		response.Text(c, http.StatusOK, "post visibility changed successfully")
+	}
+}
+
+// bindRequest берёт идентификатор поста из пути, а для устаревшего маршрута — из тела запроса
+func bindRequest(c *gin.Context) (uuid.UUID, bool, error) {
+	if id := c.Param("id"); id != "" {
+		postId, err := uuid.Parse(id)
+		if err != nil {
+			return uuid.Nil, false, apperr.Invalid("invalid post id")
+		}
+		var req VisibilityRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return uuid.Nil, false, validation.Error(err)
+		}
+		return postId, *req.Hidden, nil
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return uuid.Nil, false, validation.Error(err)
+	}
+	return req.PostId, req.Hidden, nil
+}
@@ -3,7 +3,10 @@ package logout
 import (
 	"log/slog"
 	"net/http"
+	"new_service/internal/lib/apperr"
 	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/metrics"
+	"new_service/internal/lib/response"
 	jwt_auth "new_service/pkg/auth"
 	"time"
 
@@ -13,32 +16,33 @@ import (
 
 func New(log *slog.Logger, rdb *redis.Client, jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
 
-		token, err := c.Cookie("jwt_token")
-		if err != nil {
+		token, ok := jwt_auth.TokenFromRequest(c)
+		if !ok {
 			log.Info("failed to get jwt_token")
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "failed to get jwt token"})
+			c.Error(apperr.Unauthorized("failed to get jwt token"))
 			return
 		}
 
 		jti, ok := c.Get("jti")
 		if !ok {
 			log.Info("no jti")
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "no jti"})
+			c.Error(apperr.Unauthorized("no jti"))
 			return
 		}
 
 		string_jti, ok := jti.(string)
 		if !ok {
 			log.Info("invalid jti")
-			c.JSON(http.StatusBadRequest, gin.H{"message": "invalid jti"})
+			c.Error(apperr.Invalid("invalid jti"))
 			return
 		}
 
 		expUnix, err := jwt_auth.GetExpFromRawToken(token, jwtSecret)
 		if err != nil {
 			log.Info("failed to get exp", sl.Error(err))
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "failed to get exp"})
+			c.Error(apperr.Unauthorized("failed to get exp"))
 			return
 		}
 
@@ -49,7 +53,8 @@ func New(log *slog.Logger, rdb *redis.Client, jwtSecret string) gin.HandlerFunc
 		}
 
 		rdb.Set(c.Request.Context(), string_jti, "revoked", time.Duration(ttlSeconds))
+		metrics.TokensRevoked.WithLabelValues("logout").Inc()
 		log.Info("logged out successfully")
-		c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+		response.Text(c, http.StatusOK, "logged out successfully")
 	}
 }
@@ -0,0 +1,51 @@
+package media
+
+import (
+	"context"
+	"log/slog"
+	"new_service/internal/lib/apperr"
+	"new_service/internal/lib/httpcache"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/response"
+	"new_service/internal/models"
+	jwt_auth "new_service/pkg/auth"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type MediaGetter interface {
+	GetMedia(ctx context.Context, mediaId uuid.UUID) (models.Media, error)
+}
+
+func NewGet(log *slog.Logger, mediaGetter MediaGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		mediaId, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			log.Info("invalid media id", slog.String("media_id", c.Param("id")))
+			c.Error(apperr.Invalid("invalid media id"))
+			return
+		}
+
+		media, err := mediaGetter.GetMedia(c.Request.Context(), mediaId)
+		if err != nil {
+			log.Info("failed to get media", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get media"))
+			return
+		}
+
+		isModerator := jwt_auth.HasRole(c, jwt_auth.RoleModerator, jwt_auth.RoleAdmin)
+		if media.UserId.String() != c.GetString("user_id") && !isModerator {
+			c.Error(apperr.NotFound("media does not exist"))
+			return
+		}
+
+		if httpcache.NotModified(c, httpcache.ETag(media.MediaId, media.Status, media.ProcessedAt), time.Time{}) {
+			return
+		}
+
+		response.OK(c, media)
+	}
+}
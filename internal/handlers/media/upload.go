@@ -0,0 +1,157 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"new_service/internal/config"
+	"new_service/internal/lib/apperr"
+	"new_service/internal/lib/imageproc"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/response"
+	"new_service/internal/models"
+	"os"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// multipartOverhead оставляет запас на заголовки multipart поверх размера файла
+const multipartOverhead = 1 << 20
+
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type MediaSaver interface {
+	CreateMedia(ctx context.Context, media *models.Media, quota int64) error
+	DeleteMedia(ctx context.Context, mediaId uuid.UUID) error
+}
+
+type JobQueue interface {
+	Enqueue(job imageproc.Job) error
+}
+
+func NewUpload(log *slog.Logger, cfg config.Media, mediaSaver MediaSaver, jobQueue JobQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		userId := c.GetString("user_id")
+		parsedUserId, err := uuid.Parse(userId)
+		if err != nil {
+			log.Info("invalid user id", slog.String("userId", userId))
+			c.Error(apperr.Unauthorized("invalid user id"))
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxUploadSize+multipartOverhead)
+
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				log.Info("upload is too large")
+				c.Error(apperr.TooLarge("file is too large"))
+				return
+			}
+			log.Info("invalid upload", sl.Error(err))
+			c.Error(apperr.Invalid("file must be providen"))
+			return
+		}
+		if fileHeader.Size > cfg.MaxUploadSize {
+			log.Info("upload is too large", slog.Int64("size", fileHeader.Size))
+			c.Error(apperr.TooLarge("file is too large"))
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			log.Info("failed to open upload", sl.Error(err))
+			c.Error(apperr.Invalid("failed to read file"))
+			return
+		}
+		defer file.Close()
+
+		// Тип определяем по содержимому, а не по заголовку клиента
+		head := make([]byte, 512)
+		n, err := io.ReadFull(file, head)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Info("failed to read upload", sl.Error(err))
+			c.Error(apperr.Invalid("failed to read file"))
+			return
+		}
+		contentType := http.DetectContentType(head[:n])
+		extension, known := extensions[contentType]
+		if !known || !slices.Contains(cfg.AllowedTypes, contentType) {
+			log.Info("unsupported media type", slog.String("content_type", contentType))
+			c.Error(apperr.Unsupported("unsupported media type"))
+			return
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			log.Info("failed to rewind upload", sl.Error(err))
+			c.Error(apperr.From(err, "failed to read file"))
+			return
+		}
+
+		// Обработка идёт в фоне, поэтому сохраняем загрузку во временный файл
+		spool, err := os.CreateTemp(cfg.Processing.SpoolDir, "upload-*"+extension)
+		if err != nil {
+			log.Error("failed to create spool file", sl.Error(err))
+			c.Error(apperr.From(err, "failed to save media"))
+			return
+		}
+		_, err = io.Copy(spool, file)
+		if closeErr := spool.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(spool.Name())
+			log.Error("failed to write spool file", sl.Error(err))
+			c.Error(apperr.From(err, "failed to save media"))
+			return
+		}
+
+		mediaId := uuid.New()
+		keyPrefix := parsedUserId.String() + "/" + mediaId.String()
+		media := models.Media{
+			MediaId:     mediaId,
+			UserId:      parsedUserId,
+			StorageKey:  keyPrefix,
+			ContentType: contentType,
+			Size:        fileHeader.Size,
+			Status:      models.MediaPending,
+			Variants:    []models.MediaVariant{},
+		}
+
+		if err := mediaSaver.CreateMedia(c.Request.Context(), &media, cfg.UserQuota); err != nil {
+			os.Remove(spool.Name())
+			log.Info("failed to save media", sl.Error(err))
+			c.Error(apperr.From(err, "failed to save media"))
+			return
+		}
+
+		err = jobQueue.Enqueue(imageproc.Job{
+			MediaId:     mediaId,
+			KeyPrefix:   keyPrefix,
+			ContentType: contentType,
+			SpoolPath:   spool.Name(),
+		})
+		if err != nil {
+			os.Remove(spool.Name())
+			log.Error("failed to enqueue media processing", sl.Error(err))
+			if err := mediaSaver.DeleteMedia(c.Request.Context(), mediaId); err != nil {
+				log.Error("failed to release media quota", sl.Error(err))
+			}
+			c.Error(apperr.Unavailable("media processing is busy, try again later", err))
+			return
+		}
+
+		log.Info("media accepted for processing", slog.String("media_id", mediaId.String()))
+		response.JSON(c, http.StatusAccepted, media)
+	}
+}
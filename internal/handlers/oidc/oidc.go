@@ -0,0 +1,124 @@
+package oidcLogin
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"new_service/internal/config"
+	"new_service/internal/lib/apperr"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/metrics"
+	"new_service/internal/lib/oidc"
+	"new_service/internal/lib/response"
+	jwt_auth "new_service/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// stateCookie привязывает state к браузеру, начавшему вход. Без неё чужие state и code,
+// подставленные в callback, залогинили бы жертву в аккаунт атакующего
+const (
+	stateCookie     = "oidc_state"
+	stateCookiePath = "/auth/oidc"
+)
+
+type Provider interface {
+	AuthCodeURL(ctx context.Context) (string, string, error)
+	Exchange(ctx context.Context, state string, code string) (*oidc.Identity, error)
+}
+
+type IdentityLinker interface {
+	LinkExternalIdentity(ctx context.Context, issuer string, subject string, email string, emailVerified bool) (uuid.UUID, error)
+	GetUserRole(ctx context.Context, userId uuid.UUID) (string, error)
+}
+
+func NewLogin(log *slog.Logger, cfg *config.Config, provider Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		url, state, err := provider.AuthCodeURL(c.Request.Context())
+		if err != nil {
+			log.Error("failed to start oidc login", sl.Error(err))
+			c.Error(apperr.From(err, "failed to start oidc login"))
+			return
+		}
+
+		// Lax, а не Strict: callback приходит переходом верхнего уровня с сайта провайдера
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(stateCookie, state, int(cfg.OIDC.StateTTL.Seconds()), stateCookiePath, cfg.Cookie.Domain, cfg.Cookie.Secure, true)
+		c.Redirect(http.StatusFound, url)
+	}
+}
+
+func NewCallback(log *slog.Logger, cfg *config.Config, provider Provider, identityLinker IdentityLinker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		expectedState, _ := c.Cookie(stateCookie)
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(stateCookie, "", -1, stateCookiePath, cfg.Cookie.Domain, cfg.Cookie.Secure, true)
+
+		if providerErr := c.Query("error"); providerErr != "" {
+			log.Info("oidc provider returned error", slog.String("error", providerErr))
+			c.Error(apperr.Unauthorized("oidc login failed"))
+			return
+		}
+
+		state := c.Query("state")
+		code := c.Query("code")
+		if state == "" || code == "" {
+			log.Info("invalid oidc callback: no state or code")
+			c.Error(apperr.Invalid("state and code must be providen"))
+			return
+		}
+		if expectedState == "" || subtle.ConstantTimeCompare([]byte(expectedState), []byte(state)) != 1 {
+			log.Info("oidc state does not match the browser that started the login")
+			c.Error(apperr.Invalid("invalid or expired state"))
+			return
+		}
+
+		identity, err := provider.Exchange(c.Request.Context(), state, code)
+		if err != nil {
+			if errors.Is(err, oidc.ErrInvalidState) {
+				log.Info("invalid oidc state")
+				c.Error(apperr.Invalid("invalid or expired state"))
+				return
+			}
+			log.Info("failed to exchange oidc code", sl.Error(err))
+			metrics.Logins.WithLabelValues("oidc", "failure").Inc()
+			c.Error(apperr.Unauthorized("oidc login failed"))
+			return
+		}
+
+		user_id, err := identityLinker.LinkExternalIdentity(c.Request.Context(), identity.Issuer, identity.Subject, identity.Email, identity.EmailVerified)
+		if err != nil {
+			log.Error("failed to link external identity", sl.Error(err))
+			c.Error(apperr.From(err, "failed to link external identity"))
+			return
+		}
+
+		role, err := identityLinker.GetUserRole(c.Request.Context(), user_id)
+		if err != nil {
+			log.Info("failed to get user role", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get user role"))
+			return
+		}
+
+		jwt_token, err := jwt_auth.MakeJwtToken(cfg.JWTSecret, user_id, role)
+		if err != nil {
+			log.Info("failed to create jwt", sl.Error(err))
+			c.Error(apperr.From(err, "failed to create jwt"))
+			return
+		}
+		jwt_auth.SetTokenCookie(c, cfg.Cookie, jwt_token)
+
+		log.Info("logged in via oidc", slog.String("user_id", user_id.String()))
+		metrics.Logins.WithLabelValues("oidc", "success").Inc()
+		if cfg.OIDC.PostLoginRedirect != "" {
+			c.Redirect(http.StatusFound, cfg.OIDC.PostLoginRedirect)
+			return
+		}
+		response.Text(c, http.StatusOK, "logged in successfully")
+	}
+}
@@ -0,0 +1,120 @@
+package oidcLogin
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"new_service/internal/config"
+	"new_service/internal/lib/apperr"
+	"new_service/internal/lib/oidc"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type fakeProvider struct {
+	exchanged bool
+}
+
+func (p *fakeProvider) AuthCodeURL(ctx context.Context) (string, string, error) {
+	return "https://issuer.example.com/authorize?state=state-1", "state-1", nil
+}
+
+func (p *fakeProvider) Exchange(ctx context.Context, state string, code string) (*oidc.Identity, error) {
+	p.exchanged = true
+	return &oidc.Identity{Issuer: "https://issuer.example.com", Subject: "subject", Email: "user@example.com", EmailVerified: true}, nil
+}
+
+type fakeLinker struct{}
+
+func (fakeLinker) LinkExternalIdentity(ctx context.Context, issuer string, subject string, email string, emailVerified bool) (uuid.UUID, error) {
+	return uuid.New(), nil
+}
+
+func (fakeLinker) GetUserRole(ctx context.Context, userId uuid.UUID) (string, error) {
+	return "user", nil
+}
+
+func newTestRouter(provider *fakeProvider) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{JWTSecret: "test-secret", OIDC: config.OIDC{StateTTL: 10 * time.Minute}}
+
+	router := gin.New()
+	router.Use(apperr.Middleware(log))
+	router.GET("/auth/oidc/login", NewLogin(log, cfg, provider))
+	router.GET("/auth/oidc/callback", NewCallback(log, cfg, provider, fakeLinker{}))
+	return router
+}
+
+func cookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestLoginSetsStateCookie(t *testing.T) {
+	router := newTestRouter(&fakeProvider{})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302", rec.Code)
+	}
+	state := cookie(rec, stateCookie)
+	if state == nil {
+		t.Fatal("state cookie is not set")
+	}
+	if state.Value != "state-1" || !state.HttpOnly || state.SameSite != http.SameSiteLaxMode || state.Path != stateCookiePath {
+		t.Errorf("unexpected state cookie %+v", state)
+	}
+	if state.MaxAge != 600 {
+		t.Errorf("state cookie MaxAge = %d, want 600", state.MaxAge)
+	}
+}
+
+func TestCallbackChecksStateCookie(t *testing.T) {
+	tests := []struct {
+		name       string
+		cookie     string
+		wantStatus int
+	}{
+		{"matching cookie", "state-1", http.StatusOK},
+		{"no cookie", "", http.StatusBadRequest},
+		{"cookie from another login", "state-2", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &fakeProvider{}
+			router := newTestRouter(provider)
+
+			req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?state=state-1&code=code", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: stateCookie, Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if provider.exchanged != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("code exchanged = %v", provider.exchanged)
+			}
+			if tt.wantStatus == http.StatusOK && cookie(rec, "jwt_token") == nil {
+				t.Error("jwt_token cookie is not set")
+			}
+			// Cookie со state одноразовая и сбрасывается при любом исходе
+			if cleared := cookie(rec, stateCookie); cleared == nil || cleared.MaxAge >= 0 {
+				t.Errorf("state cookie is not cleared: %+v", cleared)
+			}
+		})
+	}
+}
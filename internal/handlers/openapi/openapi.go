@@ -0,0 +1,49 @@
+package openapi
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Spec — спецификация OpenAPI 3, поддерживается вручную.
+// Тест в cmd сверяет её с зарегистрированными маршрутами и структурами запросов и ответов.
+//
+//go:embed openapi.json
+var Spec []byte
+
+const swaggerUIVersion = "5.17.14"
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Bloggery API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: %[2]q, dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// NewSpec отдаёт спецификацию как есть
+func NewSpec() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", Spec)
+	}
+}
+
+// NewDocs отдаёт страницу Swagger UI, которая загружает спецификацию по specURL
+func NewDocs(specURL string) gin.HandlerFunc {
+	page := []byte(fmt.Sprintf(docsPage, swaggerUIVersion, specURL))
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+	}
+}
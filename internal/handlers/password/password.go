@@ -0,0 +1,153 @@
+package changePassword
+
+import (
+	"context"
+	"log/slog"
+	"new_service/internal/config"
+	"new_service/internal/lib/apperr"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/response"
+	"new_service/internal/lib/validation"
+	jwt_auth "new_service/pkg/auth"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type Request struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type Response struct {
+	Message string `json:"message"`
+	Token   string `json:"token,omitempty"`
+}
+
+type PasswordChanger interface {
+	GetUserPasswordById(ctx context.Context, userId uuid.UUID) (string, error)
+	UpdateUserPassword(ctx context.Context, userId uuid.UUID, password string) error
+	GetUserRole(ctx context.Context, userId uuid.UUID) (string, error)
+}
+
+type PasswordValidator interface {
+	Validate(password string) error
+}
+
+type PasswordVerifier interface {
+	Verify(hash string, password string) (bool, bool, error)
+}
+
+type LoginGuard interface {
+	Check(ctx context.Context, ip string, account string) (time.Duration, error)
+	Fail(ctx context.Context, ip string, account string) error
+	Reset(ctx context.Context, account string) error
+}
+
+func New(log *slog.Logger, cfg *config.Config, passwordChanger PasswordChanger, validator PasswordValidator, verifier PasswordVerifier, rdb *redis.Client, loginGuard LoginGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		var req Request
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Info("invalid request", sl.Error(err))
+			c.Error(validation.Error(err))
+			return
+		}
+
+		userId := c.GetString("user_id")
+		parsedUserId, err := uuid.Parse(userId)
+		if err != nil {
+			log.Info("invalid user id", slog.String("userId", userId))
+			c.Error(apperr.Unauthorized("invalid user id"))
+			return
+		}
+
+		// Перебор текущего пароля через украденную сессию ограничиваем так же, как вход
+		account := "user:" + parsedUserId.String()
+		ip := c.ClientIP()
+		wait, err := loginGuard.Check(c.Request.Context(), ip, account)
+		if err != nil {
+			log.Error("failed to check password attempts", sl.Error(err))
+		}
+		if wait > 0 {
+			log.Info("too many password attempts")
+			c.Error(apperr.RateLimited("too many password attempts, try again later", wait))
+			return
+		}
+
+		passwordHash, err := passwordChanger.GetUserPasswordById(c.Request.Context(), parsedUserId)
+		if err != nil {
+			log.Info("failed to get user password", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get user password"))
+			return
+		}
+
+		ok, _, err := verifier.Verify(passwordHash, req.CurrentPassword)
+		if err != nil {
+			log.Info("failed to verify password", sl.Error(err))
+		}
+		if !ok {
+			log.Info("invalid current password")
+			if err := loginGuard.Fail(c.Request.Context(), ip, account); err != nil {
+				log.Error("failed to register password failure", sl.Error(err))
+			}
+			c.Error(apperr.Unauthorized("invalid current password"))
+			return
+		}
+
+		if err := loginGuard.Reset(c.Request.Context(), account); err != nil {
+			log.Error("failed to reset password attempts", sl.Error(err))
+		}
+
+		if req.NewPassword == req.CurrentPassword {
+			log.Info("new password equals current password")
+			c.Error(apperr.Invalid("new password must differ from current password"))
+			return
+		}
+
+		if err := validator.Validate(req.NewPassword); err != nil {
+			log.Info("password rejected by policy", sl.Error(err))
+			c.Error(apperr.Invalid(err.Error()))
+			return
+		}
+
+		if err := passwordChanger.UpdateUserPassword(c.Request.Context(), parsedUserId, req.NewPassword); err != nil {
+			log.Info("failed to update password", sl.Error(err))
+			c.Error(apperr.From(err, "failed to update password"))
+			return
+		}
+
+		// Текущую сессию сохраняем: новый токен выпускается до отзыва и исключается из него
+		role, err := passwordChanger.GetUserRole(c.Request.Context(), parsedUserId)
+		if err != nil {
+			log.Info("failed to get user role", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get user role"))
+			return
+		}
+
+		jwt_token, jti, err := jwt_auth.MakeJwtTokenWithId(cfg.JWTSecret, parsedUserId, role)
+		if err != nil {
+			log.Info("failed to create jwt", sl.Error(err))
+			c.Error(apperr.From(err, "failed to create jwt"))
+			return
+		}
+
+		if err := jwt_auth.RevokeUserSessions(c.Request.Context(), rdb, parsedUserId, jti); err != nil {
+			log.Error("failed to revoke sessions", sl.Error(err))
+			c.Error(apperr.From(err, "password changed, but failed to revoke sessions"))
+			return
+		}
+		jwt_auth.SetTokenCookie(c, cfg.Cookie, jwt_token)
+
+		// Клиенту с Bearer-токеном cookie не поможет, новый токен он получает в теле ответа
+		resp := Response{Message: "password changed successfully"}
+		if _, ok := jwt_auth.BearerToken(c); ok {
+			resp.Token = jwt_token
+		}
+
+		log.Info("password changed successfully")
+		response.OK(c, resp)
+	}
+}
@@ -0,0 +1,110 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"new_service/internal/lib/apperr"
+	"new_service/internal/lib/httpcache"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/response"
+	"new_service/internal/lib/validation"
+	"new_service/internal/models"
+	custom_errors "new_service/internal/repository"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type UpdateRequest struct {
+	// Пустая строка очищает поле, кроме имени пользователя
+	Username    *string `json:"username" binding:"omitnil,username"`
+	DisplayName *string `json:"display_name" binding:"omitempty,max=64"`
+	Bio         *string `json:"bio" binding:"omitempty,max=500"`
+	Website     *string `json:"website" binding:"omitempty,max=256,http_url"`
+	AvatarURL   *string `json:"avatar_url" binding:"omitempty,max=512,http_url"`
+}
+
+type ProfileGetter interface {
+	GetPublicProfile(ctx context.Context, username string) (models.PublicProfile, error)
+	GetUsernameRedirect(ctx context.Context, oldUsername string) (string, error)
+}
+
+type ProfileUpdater interface {
+	UpdateProfile(ctx context.Context, userId uuid.UUID, update models.ProfileUpdate, redirectTTL time.Duration) error
+}
+
+func NewPublic(log *slog.Logger, profileGetter ProfileGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		username := c.Param("username")
+
+		profile, err := profileGetter.GetPublicProfile(c.Request.Context(), username)
+		if err == nil {
+			if !httpcache.NotModified(c, httpcache.ETag(profile), time.Time{}) {
+				response.OK(c, profile)
+			}
+			return
+		}
+		if !errors.Is(err, custom_errors.ErrUserDoesNotExist) {
+			log.Info("failed to get profile", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get profile"))
+			return
+		}
+
+		newUsername, err := profileGetter.GetUsernameRedirect(c.Request.Context(), username)
+		if err != nil {
+			log.Info("failed to get username redirect", sl.Error(err))
+			c.Error(apperr.From(err, "failed to get profile"))
+			return
+		}
+
+		// Перенаправляем в пределах того же префикса: /users/... или /api/v1/users/...
+		c.Redirect(http.StatusMovedPermanently, path.Dir(c.Request.URL.Path)+"/"+url.PathEscape(newUsername))
+	}
+}
+
+func NewUpdate(log *slog.Logger, profileUpdater ProfileUpdater, redirectTTL time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		var req UpdateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Info("invalid request", sl.Error(err))
+			c.Error(validation.Error(err))
+			return
+		}
+
+		if req.DisplayName != nil {
+			*req.DisplayName = strings.TrimSpace(*req.DisplayName)
+		}
+
+		userId := c.GetString("user_id")
+		parsedUserId, err := uuid.Parse(userId)
+		if err != nil {
+			log.Info("invalid user id", slog.String("userId", userId))
+			c.Error(apperr.Unauthorized("invalid user id"))
+			return
+		}
+
+		err = profileUpdater.UpdateProfile(c.Request.Context(), parsedUserId, models.ProfileUpdate{
+			Username:    req.Username,
+			DisplayName: req.DisplayName,
+			Bio:         req.Bio,
+			Website:     req.Website,
+			AvatarURL:   req.AvatarURL,
+		}, redirectTTL)
+		if err != nil {
+			log.Info("failed to update profile", sl.Error(err))
+			c.Error(apperr.From(err, "failed to update profile"))
+			return
+		}
+
+		log.Info("profile updated successfully")
+		response.Text(c, http.StatusOK, "profile updated successfully")
+	}
+}
@@ -1,54 +1,57 @@
 package registration
 
 import (
-	"errors"
+	"context"
 	"log/slog"
 	"net/http"
+	"new_service/internal/lib/apperr"
 	sl "new_service/internal/lib/logger"
 	"new_service/internal/lib/response"
+	"new_service/internal/lib/validation"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type Request struct {
-	Email    string `json:"email" env-required:"true"`
-	Username string `json:"username"`
-	Password string `json:"password" env-required:"true"`
+	Email    string `json:"email" binding:"required,email,max=256"`
+	Username string `json:"username" binding:"omitempty,username"`
+	Password string `json:"password" binding:"required"`
 }
 
 type UserSaver interface {
-	SaveUser(email string, password string, username string) error
+	SaveUser(ctx context.Context, email string, password string, username string) error
 }
 
-func New(userSaver UserSaver, log *slog.Logger) gin.HandlerFunc {
+type PasswordValidator interface {
+	Validate(password string) error
+}
+
+func New(userSaver UserSaver, log *slog.Logger, validator PasswordValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
 		var req Request
 
 		if err := c.ShouldBindJSON(&req); err != nil {
 			log.Info("Invalid request", sl.Error(err))
-			c.JSON(http.StatusBadRequest, response.Error(err))
+			c.Error(validation.Error(err))
 			return
 		}
 		log.Info("Request body decoded successfully")
 
-		err := userSaver.SaveUser(req.Email, req.Password, req.Username)
+		if err := validator.Validate(req.Password); err != nil {
+			log.Info("Password rejected by policy", sl.Error(err))
+			c.Error(apperr.Invalid(err.Error()))
+			return
+		}
+
+		err := userSaver.SaveUser(c.Request.Context(), req.Email, req.Password, req.Username)
 		if err != nil {
-			var pgErr *pgconn.PgError
-			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-				log.Info("Failed to save user: email already exists", sl.Error(err))
-				c.JSON(http.StatusBadRequest, gin.H{
-					"status":  "Error",
-					"message": "email already exists",
-				})
-				return
-			}
 			log.Info("Failed to save user", sl.Error(err))
-			c.JSON(http.StatusBadRequest, response.Error(err))
+			c.Error(apperr.From(err, "failed to save user"))
 			return
 		}
 
 		log.Info("user saved sucessfully")
-		c.JSON(http.StatusOK, gin.H{"message": "registration successfull"})
+		response.Text(c, http.StatusOK, "registration successfull")
 	}
 }
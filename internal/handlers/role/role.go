@@ -0,0 +1,84 @@
+package setRole
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"new_service/internal/lib/apperr"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/response"
+	"new_service/internal/lib/validation"
+	jwt_auth "new_service/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type Request struct {
+	UserId uuid.UUID `json:"user_id" binding:"required"`
+	Role   string    `json:"role" binding:"required,oneof=user moderator admin"`
+}
+
+// RoleRequest — тело PUT .../users/:id/role
+type RoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user moderator admin"`
+}
+
+type RoleSetter interface {
+	SetUserRole(ctx context.Context, userId uuid.UUID, role string) error
+}
+
+func New(log *slog.Logger, roleSetter RoleSetter, rdb *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := sl.FromContext(c.Request.Context(), log)
+		req, err := bindRequest(c)
+		if err != nil {
+			log.Info("invalid request", sl.Error(err))
+			c.Error(err)
+			return
+		}
+
+		err = roleSetter.SetUserRole(c.Request.Context(), req.UserId, req.Role)
+		if err != nil {
+			log.Info("failed to set role", sl.Error(err))
+			c.Error(apperr.From(err, "failed to set role"))
+			return
+		}
+
+		// Роль записана в JWT, поэтому старые токены отзываем, иначе они сохранят прежние права
+		if err := jwt_auth.RevokeUserSessions(c.Request.Context(), rdb, req.UserId, ""); err != nil {
+			log.Error("failed to revoke sessions", sl.Error(err))
+			c.Error(apperr.From(err, "role changed, but failed to revoke sessions"))
+			return
+		}
+
+		log.Info("user role changed",
+			slog.String("user_id", req.UserId.String()),
+			slog.String("role", req.Role),
+			slog.String("admin_id", c.GetString("user_id")),
+		)
+		response.Text(c, http.StatusOK, "role changed successfully")
+	}
+}
+
+// bindRequest берёт идентификатор пользователя из пути, а для устаревшего маршрута — из тела запроса
+func bindRequest(c *gin.Context) (Request, error) {
+	if id := c.Param("id"); id != "" {
+		userId, err := uuid.Parse(id)
+		if err != nil {
+			return Request{}, apperr.Invalid("invalid user id")
+		}
+		var req RoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return Request{}, validation.Error(err)
+		}
+		return Request{UserId: userId, Role: req.Role}, nil
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return Request{}, validation.Error(err)
+	}
+	return req, nil
+}
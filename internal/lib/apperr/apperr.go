@@ -0,0 +1,144 @@
+package apperr
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Kind определяет, как ошибка отображается клиенту
+type Kind string
+
+const (
+	KindInvalid      Kind = "invalid"
+	KindUnauthorized Kind = "unauthorized"
+	KindForbidden    Kind = "forbidden"
+	KindNotFound     Kind = "not_found"
+	KindConflict     Kind = "conflict"
+	KindTooLarge     Kind = "too_large"
+	KindUnsupported  Kind = "unsupported"
+	KindRateLimited  Kind = "rate_limited"
+	KindUnavailable  Kind = "unavailable"
+	KindInternal     Kind = "internal"
+)
+
+func (k Kind) Status() int {
+	switch k {
+	case KindInvalid:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case KindUnsupported:
+		return http.StatusUnsupportedMediaType
+	case KindRateLimited:
+		return http.StatusTooManyRequests
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error — доменная ошибка. Message показывается клиенту, Err остаётся только в логах
+type Error struct {
+	Kind       Kind
+	Message    string
+	Fields     map[string]string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap сохраняет исходную ошибку в цепочке, чтобы errors.Is/As продолжали работать
+func Wrap(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func Invalid(message string) *Error {
+	return New(KindInvalid, message)
+}
+
+// Validation описывает ошибки отдельных полей запроса
+func Validation(message string, fields map[string]string) *Error {
+	return &Error{Kind: KindInvalid, Message: message, Fields: fields}
+}
+
+func Unauthorized(message string) *Error {
+	return New(KindUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(KindForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(KindNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(KindConflict, message)
+}
+
+func TooLarge(message string) *Error {
+	return New(KindTooLarge, message)
+}
+
+func Unsupported(message string) *Error {
+	return New(KindUnsupported, message)
+}
+
+func RateLimited(message string, retryAfter time.Duration) *Error {
+	return &Error{Kind: KindRateLimited, Message: message, RetryAfter: retryAfter}
+}
+
+func Unavailable(message string, err error) *Error {
+	return Wrap(KindUnavailable, message, err)
+}
+
+func Internal(message string, err error) *Error {
+	return Wrap(KindInternal, message, err)
+}
+
+// As находит доменную ошибку в цепочке; всё остальное считается внутренней ошибкой
+func As(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal("internal server error", err)
+}
+
+func KindOf(err error) Kind {
+	return As(err).Kind
+}
+
+// From оставляет доменную ошибку как есть, а остальные превращает во внутреннюю с сообщением message
+func From(err error, message string) error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return err
+	}
+	return Internal(message, err)
+}
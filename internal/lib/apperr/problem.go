@@ -0,0 +1,73 @@
+package apperr
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"new_service/internal/lib/httpcache"
+	sl "new_service/internal/lib/logger"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem — тело ответа об ошибке по RFC 7807
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     Kind              `json:"code"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// Middleware отдаёт последнюю ошибку из c.Errors, если обработчик сам ничего не записал
+func Middleware(log *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		Render(c, log, c.Errors.Last().Err)
+	}
+}
+
+// Recovery превращает панику в ответ 500 в том же формате
+func Recovery(log *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered any) {
+		Render(c, log, Internal("internal server error", fmt.Errorf("panic: %v", recovered)))
+	})
+}
+
+func Render(c *gin.Context, log *slog.Logger, err error) {
+	log = sl.FromContext(c.Request.Context(), log)
+	appErr := As(err)
+	status := appErr.Kind.Status()
+
+	if status >= http.StatusInternalServerError {
+		log.Error("request failed", sl.Error(err))
+	} else {
+		log.Info("request rejected", slog.String("code", string(appErr.Kind)), sl.Error(err))
+	}
+
+	if appErr.Kind == KindRateLimited && appErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
+	// Ошибка не должна заменить в кэше успешный ответ, политику маршрута перезаписываем
+	c.Header("Cache-Control", httpcache.NoStore)
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(status, Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   appErr.Message,
+		Instance: c.Request.URL.Path,
+		Code:     appErr.Kind,
+		Errors:   appErr.Fields,
+	})
+}
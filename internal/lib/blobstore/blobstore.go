@@ -0,0 +1,28 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"new_service/internal/config"
+)
+
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// DeletePrefix удаляет все объекты с ключами вида prefix/...; отсутствие объектов не ошибка
+	DeletePrefix(ctx context.Context, prefix string) error
+	// URL возвращает адрес, по которому объект можно встроить в пост
+	URL(key string) string
+}
+
+func New(ctx context.Context, cfg config.Media) (BlobStore, error) {
+	switch cfg.Storage {
+	case "local":
+		return NewLocal(cfg.LocalDir, cfg.PublicBaseURL)
+	case "s3":
+		return NewS3(ctx, cfg.S3, cfg.PublicBaseURL)
+	default:
+		return nil, fmt.Errorf("lib.blobstore.New: unknown storage %q", cfg.Storage)
+	}
+}
@@ -0,0 +1,302 @@
+package blobstore
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"new_service/internal/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// fakeS3 — минимальная замена MinIO: бакеты, PUT/GET/DELETE объектов и ListObjectsV2
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+type listBucketResult struct {
+	XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name        string
+	Prefix      string
+	KeyCount    int
+	MaxKeys     int
+	IsTruncated bool
+	Contents    []listObject
+}
+
+type listObject struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	bucket, exists := f.buckets[bucketName]
+
+	switch {
+	case key == "" && r.Method == http.MethodPut:
+		f.buckets[bucketName] = make(map[string][]byte)
+	case !exists:
+		w.WriteHeader(http.StatusNotFound)
+		if r.Method != http.MethodHead {
+			io.WriteString(w, `<Error><Code>NoSuchBucket</Code><Message>bucket does not exist</Message></Error>`)
+		}
+	case key == "" && r.Method == http.MethodHead:
+	case key == "" && r.Method == http.MethodGet:
+		prefix := r.URL.Query().Get("prefix")
+		result := listBucketResult{Name: bucketName, Prefix: prefix, MaxKeys: 1000}
+		for objectKey, data := range bucket {
+			if strings.HasPrefix(objectKey, prefix) {
+				sum := md5.Sum(data)
+				result.Contents = append(result.Contents, listObject{
+					Key:          objectKey,
+					Size:         int64(len(data)),
+					ETag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+					LastModified: time.Now().UTC().Format(time.RFC3339),
+				})
+			}
+		}
+		sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+		result.KeyCount = len(result.Contents)
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(result)
+	case r.Method == http.MethodPut:
+		data, err := readPayload(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bucket[key] = data
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	case r.Method == http.MethodGet:
+		data, ok := bucket[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>key does not exist</Message></Error>`)
+			return
+		}
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// readPayload снимает aws-chunked кодирование, которым minio-go подписывает PUT по http
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	reader := bufio.NewReader(r.Body)
+	var data []byte
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+		if _, err := reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// newTestS3 подключается к MinIO из TEST_S3_ENDPOINT, а без него — к fakeS3
+func newTestS3(t *testing.T) *S3Store {
+	t.Helper()
+
+	cfg := config.S3Storage{
+		Endpoint:   os.Getenv("TEST_S3_ENDPOINT"),
+		Region:     "us-east-1",
+		Bucket:     "bloggery-test",
+		AccessKey:  os.Getenv("TEST_S3_ACCESS_KEY"),
+		SecretKey:  os.Getenv("TEST_S3_SECRET_KEY"),
+		DisableSSL: true,
+	}
+	if cfg.Endpoint == "" {
+		server := httptest.NewServer(&fakeS3{buckets: make(map[string]map[string][]byte)})
+		t.Cleanup(server.Close)
+		endpoint, _ := url.Parse(server.URL)
+		cfg.Endpoint, cfg.AccessKey, cfg.SecretKey = endpoint.Host, "minioadmin", "minioadmin"
+	}
+
+	store, err := NewS3(context.Background(), cfg, "")
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	return store
+}
+
+// storeCase — хранилище и способ проверить объект в обход интерфейса
+type storeCase struct {
+	name  string
+	store BlobStore
+	read  func(t *testing.T, key string) ([]byte, bool)
+}
+
+func newStoreCases(t *testing.T) []storeCase {
+	dir := t.TempDir()
+	local, err := NewLocal(dir, "/media")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	s3 := newTestS3(t)
+
+	return []storeCase{
+		{
+			name:  "local",
+			store: local,
+			read: func(t *testing.T, key string) ([]byte, bool) {
+				data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(key)))
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil, false
+				}
+				if err != nil {
+					t.Fatalf("read %q: %v", key, err)
+				}
+				return data, true
+			},
+		},
+		{
+			name:  "s3",
+			store: s3,
+			read: func(t *testing.T, key string) ([]byte, bool) {
+				object, err := s3.client.GetObject(context.Background(), s3.bucket, key, minio.GetObjectOptions{})
+				if err != nil {
+					t.Fatalf("get %q: %v", key, err)
+				}
+				defer object.Close()
+				data, err := io.ReadAll(object)
+				if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+					return nil, false
+				}
+				if err != nil {
+					t.Fatalf("read %q: %v", key, err)
+				}
+				return data, true
+			},
+		},
+	}
+}
+
+func put(t *testing.T, store BlobStore, key string, data string) {
+	t.Helper()
+
+	if err := store.Put(context.Background(), key, strings.NewReader(data), int64(len(data)), "text/plain"); err != nil {
+		t.Fatalf("Put %q: %v", key, err)
+	}
+}
+
+func TestPutAndDelete(t *testing.T) {
+	for _, tc := range newStoreCases(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			key := "user/media/original.png"
+
+			put(t, tc.store, key, "image data")
+			if data, ok := tc.read(t, key); !ok || string(data) != "image data" {
+				t.Fatalf("after Put: data = %q, exists = %v", data, ok)
+			}
+
+			if err := tc.store.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, ok := tc.read(t, key); ok {
+				t.Error("object exists after Delete")
+			}
+			if err := tc.store.Delete(ctx, key); err != nil {
+				t.Errorf("Delete of a missing object: %v", err)
+			}
+		})
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	for _, tc := range newStoreCases(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			deleted := []string{"user/m1/original.png", "user/m1/thumbnail.webp", "user/m1/thumbnail.jpg"}
+			// m10 начинается с того же префикса, но принадлежит другому файлу
+			kept := []string{"user/m10/original.png", "user/m2/original.png"}
+			for _, key := range append(deleted, kept...) {
+				put(t, tc.store, key, key)
+			}
+
+			if err := tc.store.DeletePrefix(ctx, "user/m1"); err != nil {
+				t.Fatalf("DeletePrefix: %v", err)
+			}
+			for _, key := range deleted {
+				if _, ok := tc.read(t, key); ok {
+					t.Errorf("%q exists after DeletePrefix", key)
+				}
+			}
+			for _, key := range kept {
+				if _, ok := tc.read(t, key); !ok {
+					t.Errorf("%q was deleted with another prefix", key)
+				}
+			}
+
+			if err := tc.store.DeletePrefix(ctx, "user/missing"); err != nil {
+				t.Errorf("DeletePrefix of a missing prefix: %v", err)
+			}
+		})
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	store, err := NewLocal(t.TempDir(), "/media")
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	for _, key := range []string{"../outside", "/etc/passwd", "user/../../outside"} {
+		if err := store.Put(context.Background(), key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put accepted key %q", key)
+		}
+		if err := store.DeletePrefix(context.Background(), key); err == nil {
+			t.Errorf("DeletePrefix accepted key %q", key)
+		}
+	}
+}
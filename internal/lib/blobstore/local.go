@@ -0,0 +1,98 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type LocalStore struct {
+	dir     string
+	baseURL string
+}
+
+func NewLocal(dir string, baseURL string) (*LocalStore, error) {
+	const op = "lib.blobstore.NewLocal"
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &LocalStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+func (s *LocalStore) Dir() string {
+	return s.dir
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	const op = "lib.blobstore.LocalStore.Put"
+
+	path, err := s.path(key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Пишем во временный файл, чтобы читатели не увидели недописанный объект
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	const op = "lib.blobstore.LocalStore.Delete"
+
+	path, err := s.path(key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) DeletePrefix(ctx context.Context, prefix string) error {
+	const op = "lib.blobstore.LocalStore.DeletePrefix"
+
+	path, err := s.path(prefix)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if !filepath.IsLocal(key) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,92 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"new_service/internal/config"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Store работает с любым S3-совместимым хранилищем, в том числе с локальным MinIO
+type S3Store struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+func NewS3(ctx context.Context, cfg config.S3Storage, publicBaseURL string) (*S3Store, error) {
+	const op = "lib.blobstore.NewS3"
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: !cfg.DisableSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// Без публичного адреса отдаём прямые ссылки на бакет
+	baseURL := strings.TrimSuffix(publicBaseURL, "/")
+	if baseURL == "" || strings.HasPrefix(baseURL, "/") {
+		baseURL = client.EndpointURL().String() + "/" + cfg.Bucket
+	}
+
+	return &S3Store{client: client, bucket: cfg.Bucket, baseURL: baseURL}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	const op = "lib.blobstore.S3Store.Put"
+
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	const op = "lib.blobstore.S3Store.Delete"
+
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *S3Store) DeletePrefix(ctx context.Context, prefix string) error {
+	const op = "lib.blobstore.S3Store.DeletePrefix"
+
+	// Отмена контекста останавливает листинг, если выходим из цикла раньше
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	objects := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix + "/", Recursive: true})
+	for object := range objects {
+		if object.Err != nil {
+			return fmt.Errorf("%s: %w", op, object.Err)
+		}
+		if err := s.client.RemoveObject(ctx, s.bucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+func (s *S3Store) URL(key string) string {
+	return s.baseURL + "/" + key
+}
@@ -0,0 +1,104 @@
+package bruteforce
+
+import (
+	"context"
+	"fmt"
+	"new_service/internal/config"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "bruteforce"
+
+type Guard struct {
+	rdb *redis.Client
+	cfg config.BruteForce
+}
+
+func New(rdb *redis.Client, cfg config.BruteForce) *Guard {
+	return &Guard{rdb: rdb, cfg: cfg}
+}
+
+// Check возвращает время, которое нужно подождать перед следующей попыткой входа
+func (g *Guard) Check(ctx context.Context, ip string, account string) (time.Duration, error) {
+	const op = "lib.bruteforce.Check"
+
+	pipe := g.rdb.Pipeline()
+	ipTTL := pipe.PTTL(ctx, blockKey("ip", ip))
+	accountTTL := pipe.PTTL(ctx, blockKey("account", normalize(account)))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return max(ipTTL.Val(), accountTTL.Val(), 0), nil
+}
+
+func (g *Guard) Fail(ctx context.Context, ip string, account string) error {
+	const op = "lib.bruteforce.Fail"
+
+	account = normalize(account)
+
+	pipe := g.rdb.TxPipeline()
+	ipFails := pipe.Incr(ctx, failKey("ip", ip))
+	pipe.ExpireNX(ctx, failKey("ip", ip), g.cfg.Window)
+	accountFails := pipe.Incr(ctx, failKey("account", account))
+	pipe.ExpireNX(ctx, failKey("account", account), g.cfg.Window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if ipFails.Val() >= g.cfg.IPLockoutAttempts {
+		if err := g.rdb.Set(ctx, blockKey("ip", ip), "locked", g.cfg.LockoutDuration).Err(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	delay := g.accountDelay(accountFails.Val())
+	if delay > 0 {
+		if err := g.rdb.Set(ctx, blockKey("account", account), "locked", delay).Err(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+func (g *Guard) Reset(ctx context.Context, account string) error {
+	const op = "lib.bruteforce.Reset"
+
+	account = normalize(account)
+	if err := g.rdb.Del(ctx, failKey("account", account), blockKey("account", account)).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// accountDelay растёт экспоненциально после бесплатных попыток и заменяется блокировкой после порога
+func (g *Guard) accountDelay(fails int64) time.Duration {
+	if fails >= g.cfg.AccountLockoutAttempts {
+		return g.cfg.LockoutDuration
+	}
+	if fails <= g.cfg.FreeAttempts {
+		return 0
+	}
+
+	delay := g.cfg.BaseDelay
+	for i := g.cfg.FreeAttempts + 1; i < fails && delay < g.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, g.cfg.MaxDelay)
+}
+
+func normalize(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
+
+func failKey(kind string, value string) string {
+	return fmt.Sprintf("%s:fail:%s:%s", keyPrefix, kind, value)
+}
+
+func blockKey(kind string, value string) string {
+	return fmt.Sprintf("%s:block:%s:%s", keyPrefix, kind, value)
+}
@@ -0,0 +1,162 @@
+package bruteforce
+
+import (
+	"context"
+	"fmt"
+	"new_service/internal/config"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var testConfig = config.BruteForce{
+	Window:                 15 * time.Minute,
+	FreeAttempts:           3,
+	BaseDelay:              time.Second,
+	MaxDelay:               8 * time.Second,
+	AccountLockoutAttempts: 10,
+	IPLockoutAttempts:      5,
+	LockoutDuration:        15 * time.Minute,
+}
+
+func newTestGuard(t *testing.T) (*Guard, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return New(rdb, testConfig), mr
+}
+
+func fail(t *testing.T, g *Guard, ip string, account string, times int) {
+	t.Helper()
+	for range times {
+		if err := g.Fail(context.Background(), ip, account); err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+	}
+}
+
+func check(t *testing.T, g *Guard, ip string, account string) time.Duration {
+	t.Helper()
+	wait, err := g.Check(context.Background(), ip, account)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	return wait
+}
+
+func TestAccountDelay(t *testing.T) {
+	g := New(nil, testConfig)
+
+	tests := []struct {
+		fails int64
+		want  time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 8 * time.Second},
+		{8, 8 * time.Second},
+		{9, 8 * time.Second},
+		{10, 15 * time.Minute},
+		{20, 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := g.accountDelay(tt.fails); got != tt.want {
+			t.Errorf("accountDelay(%d) = %v, want %v", tt.fails, got, tt.want)
+		}
+	}
+}
+
+func TestFailDelaysAccount(t *testing.T) {
+	g, mr := newTestGuard(t)
+
+	// Бесплатные попытки не задерживают следующую
+	fail(t, g, "192.0.2.1", "user@example.com", 3)
+	if wait := check(t, g, "192.0.2.1", "user@example.com"); wait != 0 {
+		t.Fatalf("after free attempts: wait = %v, want 0", wait)
+	}
+
+	fail(t, g, "192.0.2.1", "user@example.com", 1)
+	if wait := check(t, g, "192.0.2.1", "user@example.com"); wait != time.Second {
+		t.Errorf("after 4 failures: wait = %v, want 1s", wait)
+	}
+	// Аккаунт нормализуется, а задержка не зависит от адреса
+	if wait := check(t, g, "192.0.2.2", " User@Example.com "); wait != time.Second {
+		t.Errorf("same account from another address: wait = %v, want 1s", wait)
+	}
+	if wait := check(t, g, "192.0.2.1", "other@example.com"); wait != 0 {
+		t.Errorf("other account: wait = %v, want 0", wait)
+	}
+
+	mr.FastForward(time.Second)
+	if wait := check(t, g, "192.0.2.1", "user@example.com"); wait != 0 {
+		t.Errorf("after the delay: wait = %v, want 0", wait)
+	}
+}
+
+func TestFailLocksAccount(t *testing.T) {
+	g, mr := newTestGuard(t)
+
+	// Адреса разные, чтобы не сработала блокировка по IP
+	for i := range testConfig.AccountLockoutAttempts {
+		fail(t, g, fmt.Sprintf("192.0.2.%d", i+1), "user@example.com", 1)
+	}
+	if wait := check(t, g, "198.51.100.1", "user@example.com"); wait != testConfig.LockoutDuration {
+		t.Errorf("after %d failures: wait = %v, want %v", testConfig.AccountLockoutAttempts, wait, testConfig.LockoutDuration)
+	}
+
+	mr.FastForward(testConfig.LockoutDuration)
+	if wait := check(t, g, "198.51.100.1", "user@example.com"); wait != 0 {
+		t.Errorf("after the lockout: wait = %v, want 0", wait)
+	}
+}
+
+func TestFailLocksIP(t *testing.T) {
+	g, _ := newTestGuard(t)
+
+	// Каждый аккаунт остаётся в бесплатных попытках, считается только адрес
+	accounts := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}
+	for _, account := range accounts {
+		fail(t, g, "192.0.2.1", account, 1)
+	}
+	if wait := check(t, g, "192.0.2.1", "new@example.com"); wait != 0 {
+		t.Fatalf("below the IP threshold: wait = %v, want 0", wait)
+	}
+
+	fail(t, g, "192.0.2.1", "e@example.com", 1)
+	if wait := check(t, g, "192.0.2.1", "new@example.com"); wait != testConfig.LockoutDuration {
+		t.Errorf("at the IP threshold: wait = %v, want %v", wait, testConfig.LockoutDuration)
+	}
+	if wait := check(t, g, "192.0.2.2", "new@example.com"); wait != 0 {
+		t.Errorf("other address: wait = %v, want 0", wait)
+	}
+}
+
+func TestReset(t *testing.T) {
+	g, _ := newTestGuard(t)
+
+	fail(t, g, "192.0.2.1", "user@example.com", 4)
+	if wait := check(t, g, "192.0.2.1", "user@example.com"); wait == 0 {
+		t.Fatal("account is not delayed before Reset")
+	}
+
+	if err := g.Reset(context.Background(), "User@Example.com"); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if wait := check(t, g, "192.0.2.1", "user@example.com"); wait != 0 {
+		t.Errorf("after Reset: wait = %v, want 0", wait)
+	}
+
+	// Счётчик начинается заново: следующая ошибка снова бесплатная.
+	// Адрес другой, потому что Reset не сбрасывает счётчик по IP
+	fail(t, g, "192.0.2.2", "user@example.com", 1)
+	if wait := check(t, g, "192.0.2.2", "user@example.com"); wait != 0 {
+		t.Errorf("first failure after Reset: wait = %v, want 0", wait)
+	}
+}
@@ -0,0 +1,131 @@
+package cors
+
+import (
+	"net/http"
+	"new_service/internal/config"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// origins сопоставляет Origin запроса с настроенными источниками
+type origins struct {
+	any   bool
+	exact map[string]bool
+	// wildcards — пары scheme:// и .domain для "scheme://*.domain"
+	wildcards [][2]string
+}
+
+func newOrigins(allowed []string) origins {
+	o := origins{exact: make(map[string]bool)}
+	for _, origin := range allowed {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			o.any = true
+		case strings.Contains(origin, "://*."):
+			scheme, domain, _ := strings.Cut(origin, "*")
+			o.wildcards = append(o.wildcards, [2]string{scheme, domain})
+		default:
+			o.exact[origin] = true
+		}
+	}
+	return o
+}
+
+func (o origins) allowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	if o.any || o.exact[origin] {
+		return true
+	}
+	for _, wildcard := range o.wildcards {
+		subdomain, ok := strings.CutPrefix(origin, wildcard[0])
+		if !ok {
+			continue
+		}
+		// Сам домен без поддомена шаблону не соответствует
+		if label, ok := strings.CutSuffix(subdomain, wildcard[1]); ok && label != "" && !strings.ContainsAny(label, "/:@") {
+			return true
+		}
+	}
+	return false
+}
+
+// withHeader добавляет заголовок в список, если его там ещё нет; имена заголовков не зависят от регистра
+func withHeader(headers []string, name string) []string {
+	if slices.ContainsFunc(headers, func(header string) bool { return strings.EqualFold(header, name) }) {
+		return headers
+	}
+	return append(slices.Clip(headers), name)
+}
+
+// Middleware отвечает на preflight-запросы и добавляет CORS-заголовки к ответам для разрешённых источников.
+// Стоит до авторизации и лимитов: preflight приходит без cookie и токена.
+// Заголовок CSRF берётся из его конфигурации: SPA отправляет токен в нём и читает новый из ответа
+func Middleware(cfg config.CORS, csrf config.CSRF) gin.HandlerFunc {
+	if len(cfg.AllowedOrigins) == 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	origins := newOrigins(cfg.AllowedOrigins)
+	// С credentials браузер не принимает "*", поэтому тогда источник всегда возвращается явно
+	anyOrigin := origins.any && !cfg.AllowCredentials
+	allowedHeaders, exposedHeaders := cfg.AllowedHeaders, cfg.ExposedHeaders
+	if !csrf.Disabled {
+		allowedHeaders = withHeader(allowedHeaders, csrf.HeaderName)
+		exposedHeaders = withHeader(exposedHeaders, csrf.HeaderName)
+	}
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(allowedHeaders, ", ")
+	exposed := strings.Join(exposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		if !anyOrigin {
+			header.Add("Vary", "Origin")
+		}
+
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if origin == "" || !origins.allowed(origin) {
+			// Без Access-Control-Allow-Origin браузер сам не отдаст ответ странице
+			if preflight {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if anyOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", methods)
+			header.Set("Access-Control-Allow-Headers", headers)
+			if cfg.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if exposed != "" {
+			header.Set("Access-Control-Expose-Headers", exposed)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,103 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"new_service/internal/config"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOriginsAllowed(t *testing.T) {
+	o := newOrigins([]string{"https://app.example.com", "https://*.example.org", "http://localhost:3000/"})
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"exact origin", "https://app.example.com", true},
+		{"exact origin in other case", "HTTPS://App.Example.com", true},
+		{"exact origin with trailing slash in config", "http://localhost:3000", true},
+		{"other subdomain of an exact origin", "https://api.example.com", false},
+		{"exact origin with other port", "https://app.example.com:8443", false},
+		{"scheme mismatch for exact origin", "http://app.example.com", false},
+		{"wildcard subdomain", "https://app.example.org", true},
+		{"nested wildcard subdomain", "https://a.b.example.org", true},
+		{"bare apex domain", "https://example.org", false},
+		{"scheme mismatch for wildcard", "http://app.example.org", false},
+		{"suffix without a dot", "https://evilexample.org", false},
+		{"wildcard domain as a subdomain of another host", "https://app.example.org.evil.com", false},
+		{"userinfo in origin", "https://evil.com@app.example.org", false},
+		{"port is not matched by wildcard", "https://app.example.org:8443", false},
+		{"empty origin", "", false},
+		{"null origin", "null", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := o.allowed(tt.origin); got != tt.want {
+				t.Errorf("allowed(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+
+	if !newOrigins([]string{"*"}).allowed("https://any.example.net") {
+		t.Error(`"*" must allow any origin`)
+	}
+}
+
+func TestMiddlewareCSRFHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	corsCfg := config.CORS{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type"},
+		ExposedHeaders:   []string{"ETag"},
+		AllowCredentials: true,
+	}
+	tests := []struct {
+		name        string
+		csrf        config.CSRF
+		wantAllowed string
+		wantExposed string
+	}{
+		{"default header", config.CSRF{HeaderName: "X-CSRF-Token"}, "Content-Type, X-CSRF-Token", "ETag, X-CSRF-Token"},
+		{"renamed header", config.CSRF{HeaderName: "X-XSRF-Token"}, "Content-Type, X-XSRF-Token", "ETag, X-XSRF-Token"},
+		{"header already listed", config.CSRF{HeaderName: "content-type"}, "Content-Type", "ETag, content-type"},
+		{"csrf disabled", config.CSRF{Disabled: true, HeaderName: "X-CSRF-Token"}, "Content-Type", "ETag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Middleware(corsCfg, tt.csrf))
+			router.GET("/", func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+
+			preflight := httptest.NewRequest(http.MethodOptions, "/", nil)
+			preflight.Header.Set("Origin", "https://app.example.com")
+			preflight.Header.Set("Access-Control-Request-Method", "POST")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, preflight)
+			if got := rec.Header().Get("Access-Control-Allow-Headers"); got != tt.wantAllowed {
+				t.Errorf("Access-Control-Allow-Headers = %q, want %q", got, tt.wantAllowed)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Origin", "https://app.example.com")
+			rec = httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if got := rec.Header().Get("Access-Control-Expose-Headers"); got != tt.wantExposed {
+				t.Errorf("Access-Control-Expose-Headers = %q, want %q", got, tt.wantExposed)
+			}
+		})
+	}
+
+	// Общий срез из конфигурации не должен меняться
+	if strings.Join(corsCfg.AllowedHeaders, ",") != "Content-Type" || strings.Join(corsCfg.ExposedHeaders, ",") != "ETag" {
+		t.Errorf("config was modified: %v, %v", corsCfg.AllowedHeaders, corsCfg.ExposedHeaders)
+	}
+}
@@ -0,0 +1,24 @@
+package deprecation
+
+import (
+	"fmt"
+	"net/http"
+	"new_service/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware помечает маршруты устаревшими: Deprecation (RFC 9745), Sunset (RFC 8594)
+// и ссылка на документацию, где описана замена
+func Middleware(cfg config.LegacyAPI, docsURL string) gin.HandlerFunc {
+	deprecation := fmt.Sprintf("@%d", cfg.DeprecatedAt.Unix())
+	sunset := cfg.Sunset.UTC().Format(http.TimeFormat)
+	link := fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, docsURL)
+
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunset)
+		c.Header("Link", link)
+		c.Next()
+	}
+}
@@ -0,0 +1,142 @@
+package httpcache
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// encodings в порядке предпочтения сервера
+var encodings = []string{"br", "gzip"}
+
+// minSize — ответы меньше не сжимаются: служебные байты формата съедят выигрыш
+const minSize = 1024
+
+// Compress сжимает ответ в br или gzip по Accept-Encoding. Ответы с Content-Encoding и уже сжатые
+// форматы пропускаются. К сильному ETag добавляется суффикс кодирования, NotModified его учитывает
+func Compress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiate(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+		c.Writer = w
+		defer w.close()
+
+		c.Next()
+	}
+}
+
+// negotiate выбирает кодирование с наибольшим q, при равенстве — по порядку encodings
+func negotiate(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				weight = parsed
+			}
+		}
+		weights[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+
+	best, bestWeight := "", 0.0
+	for _, encoding := range encodings {
+		weight, ok := weights[encoding]
+		if !ok {
+			weight = weights["*"]
+		}
+		if weight > bestWeight {
+			best, bestWeight = encoding, weight
+		}
+	}
+	return best
+}
+
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	encoder  io.WriteCloser
+	decided  bool
+}
+
+// Write решает, сжимать ли ответ, по первому куску тела: к этому моменту заголовки уже выставлены
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		if w.compressible(len(data)) {
+			w.start()
+		}
+	}
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.encoder.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Flush() {
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) compressible(size int) bool {
+	header := w.Header()
+	if size < minSize || header.Get("Content-Encoding") != "" {
+		return false
+	}
+	switch w.Status() {
+	case http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+
+	mediaType, _, _ := strings.Cut(header.Get("Content-Type"), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" ||
+		mediaType == "application/problem+json" ||
+		mediaType == "application/javascript" ||
+		mediaType == "image/svg+xml"
+}
+
+func (w *compressWriter) start() {
+	header := w.Header()
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+w.encoding+`"`)
+	}
+
+	switch w.encoding {
+	case "br":
+		w.encoder = brotli.NewWriterLevel(w.ResponseWriter, brotli.DefaultCompression)
+	default:
+		w.encoder = gzip.NewWriter(w.ResponseWriter)
+	}
+}
+
+func (w *compressWriter) close() {
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Private — ответ зависит от пользователя; no-cache заставляет перепроверять его по ETag
+	Private = "private, no-cache"
+	// Public разрешает общим кэшам хранить опубликованные данные минуту
+	Public = "public, max-age=60"
+	// NoStore — для ошибок, которые нельзя переиспользовать
+	NoStore = "no-store"
+)
+
+// ETag строит сильный ETag из версии ресурса, например идентификатора и updated_at
+func ETag(version ...any) string {
+	data, _ := json.Marshal(version)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// CacheControl задаёт политику по умолчанию для группы маршрутов; обработчик может её переопределить
+func CacheControl(policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", policy)
+		c.Next()
+	}
+}
+
+// NotModified выставляет ETag и Last-Modified и, если копия клиента актуальна, отвечает 304.
+// Нулевой lastModified не выставляется: для списков он не отражает удаления.
+// Возвращает true, если ответ уже отправлен
+func NotModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	matched, ok := fresh(c.Request, etag, lastModified)
+	if !ok {
+		return false
+	}
+	// В 304 возвращаем ETag клиента: в нём может быть суффикс кодирования сжатого ответа
+	if matched != "" {
+		c.Header("ETag", matched)
+	}
+	c.Status(http.StatusNotModified)
+	c.Writer.WriteHeaderNow()
+	return true
+}
+
+// fresh следует RFC 9110: If-None-Match важнее If-Modified-Since.
+// Возвращает совпавший ETag из If-None-Match, если сравнение шло по нему
+func fresh(r *http.Request, etag string, lastModified time.Time) (string, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return "", false
+	}
+
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" {
+				return "", true
+			}
+			if sameETag(candidate, etag) {
+				return strings.TrimPrefix(candidate, "W/"), true
+			}
+		}
+		return "", false
+	}
+
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		return "", err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return "", false
+}
+
+// sameETag сравнивает слабо (без W/) и без суффикса кодирования, который добавляет Compress
+func sameETag(candidate string, etag string) bool {
+	candidate = strings.TrimPrefix(candidate, "W/")
+	for _, encoding := range encodings {
+		if trimmed, ok := strings.CutSuffix(candidate, "-"+encoding+`"`); ok {
+			candidate = trimmed + `"`
+			break
+		}
+	}
+	return candidate == etag
+}
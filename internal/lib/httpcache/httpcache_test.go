@@ -0,0 +1,174 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0.8, gzip;q=0.8", "br"},
+		{"GZIP", "gzip"},
+		{"*", "br"},
+		{"*;q=0.5, br;q=0", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"gzip;q=invalid", "gzip"},
+	}
+	for _, tt := range tests {
+		if got := negotiate(tt.header); got != tt.want {
+			t.Errorf("negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestSameETag(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		candidate string
+		want      bool
+	}{
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"abc-br"`, true},
+		{`"abc-gzip"`, true},
+		{`W/"abc-gzip"`, true},
+		{`"abc-deflate"`, false},
+		{`"abd"`, false},
+		{`abc`, false},
+	}
+	for _, tt := range tests {
+		if got := sameETag(tt.candidate, etag); got != tt.want {
+			t.Errorf("sameETag(%q, %q) = %v, want %v", tt.candidate, etag, got, tt.want)
+		}
+	}
+}
+
+func TestFresh(t *testing.T) {
+	const etag = `"abc"`
+	modified := time.Date(2026, 1, 1, 12, 0, 0, 500, time.UTC)
+
+	tests := []struct {
+		name        string
+		method      string
+		header      map[string]string
+		wantMatched string
+		wantFresh   bool
+	}{
+		{"no validators", http.MethodGet, nil, "", false},
+		{"matching etag", http.MethodGet, map[string]string{"If-None-Match": `"abc"`}, `"abc"`, true},
+		{"etag in a list", http.MethodGet, map[string]string{"If-None-Match": `"old", "abc-br"`}, `"abc-br"`, true},
+		{"weak etag", http.MethodGet, map[string]string{"If-None-Match": `W/"abc"`}, `"abc"`, true},
+		{"wildcard", http.MethodGet, map[string]string{"If-None-Match": "*"}, "", true},
+		{"other etag", http.MethodGet, map[string]string{"If-None-Match": `"old"`}, "", false},
+		{"etag takes precedence over date", http.MethodGet, map[string]string{
+			"If-None-Match":     `"old"`,
+			"If-Modified-Since": modified.Format(http.TimeFormat),
+		}, "", false},
+		{"not modified since", http.MethodGet, map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, "", true},
+		{"modified since", http.MethodGet, map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)}, "", false},
+		{"invalid date", http.MethodGet, map[string]string{"If-Modified-Since": "yesterday"}, "", false},
+		{"head", http.MethodHead, map[string]string{"If-None-Match": `"abc"`}, `"abc"`, true},
+		{"unsafe method", http.MethodPost, map[string]string{"If-None-Match": `"abc"`}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			for name, value := range tt.header {
+				r.Header.Set(name, value)
+			}
+			matched, ok := fresh(r, etag, modified)
+			if ok != tt.wantFresh || matched != tt.wantMatched {
+				t.Errorf("fresh = (%q, %v), want (%q, %v)", matched, ok, tt.wantMatched, tt.wantFresh)
+			}
+		})
+	}
+}
+
+func newTestRouter(body string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Compress(), CacheControl(Private))
+	router.GET("/", func(c *gin.Context) {
+		if NotModified(c, ETag("post", 1), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			return
+		}
+		c.Data(http.StatusOK, "application/json", []byte(body))
+	})
+	return router
+}
+
+func TestNotModified(t *testing.T) {
+	router := newTestRouter(`{"data":{}}`)
+	etag := ETag("post", 1)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("ETag"); got != etag {
+		t.Errorf("ETag = %q, want %q", got, etag)
+	}
+	if got := rec.Header().Get("Last-Modified"); got != "Thu, 01 Jan 2026 00:00:00 GMT" {
+		t.Errorf("Last-Modified = %q", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want 304", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("304 has a body of %d bytes", rec.Body.Len())
+	}
+	if got := rec.Header().Get("Cache-Control"); got != Private {
+		t.Errorf("Cache-Control = %q, want %q", got, Private)
+	}
+}
+
+func TestNotModifiedCompressed(t *testing.T) {
+	router := newTestRouter(`{"data":"` + strings.Repeat("a", 2*minSize) + `"}`)
+	compressedETag := strings.TrimSuffix(ETag("post", 1), `"`) + `-gzip"`
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := rec.Header().Get("ETag"); got != compressedETag {
+		t.Fatalf("ETag = %q, want %q", got, compressedETag)
+	}
+
+	// Клиент возвращает ETag сжатого ответа, и 304 отдаёт его же
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", compressedETag)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want 304", rec.Code)
+	}
+	if got := rec.Header().Get("ETag"); got != compressedETag {
+		t.Errorf("ETag = %q, want %q", got, compressedETag)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("304 has Content-Encoding %q", got)
+	}
+}
@@ -0,0 +1,255 @@
+package imageproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"new_service/internal/config"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/models"
+	"os"
+	"sync"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrQueueFull = errors.New("image processing queue is full")
+	ErrStopped   = errors.New("image processor is stopped")
+	ErrTooLarge  = errors.New("image dimensions exceed the limit")
+)
+
+type Job struct {
+	MediaId     uuid.UUID
+	KeyPrefix   string
+	ContentType string
+	// SpoolPath - временный файл с исходной загрузкой, удаляется после обработки
+	SpoolPath string
+}
+
+type MediaStore interface {
+	SetMediaProcessing(ctx context.Context, mediaId uuid.UUID) error
+	CompleteMedia(ctx context.Context, mediaId uuid.UUID, width int, height int, url string, size int64, variants []models.MediaVariant) error
+	FailMedia(ctx context.Context, mediaId uuid.UUID, reason string) error
+}
+
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	URL(key string) string
+}
+
+type variantSpec struct {
+	name    string
+	maxSide int
+}
+
+type Processor struct {
+	log        *slog.Logger
+	cfg        config.ImageProcessing
+	mediaStore MediaStore
+	blobStore  BlobStore
+	variants   []variantSpec
+	jobs       chan Job
+	wg         sync.WaitGroup
+	// mu защищает jobs от отправки после закрытия в Stop
+	mu      sync.RWMutex
+	stopped bool
+}
+
+func New(log *slog.Logger, cfg config.ImageProcessing, mediaStore MediaStore, blobStore BlobStore) *Processor {
+	return &Processor{
+		log:        log,
+		cfg:        cfg,
+		mediaStore: mediaStore,
+		blobStore:  blobStore,
+		variants: []variantSpec{
+			{name: "thumbnail", maxSide: cfg.ThumbnailSize},
+			{name: "medium", maxSide: cfg.MediumSize},
+			{name: "large", maxSide: cfg.LargeSize},
+		},
+		jobs: make(chan Job, cfg.QueueSize),
+	}
+}
+
+func (p *Processor) Start(ctx context.Context) {
+	for i := 0; i < p.cfg.Workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				p.handle(ctx, job)
+			}
+		}()
+	}
+}
+
+// Stop дожидается обработки уже поставленных в очередь задач
+func (p *Processor) Stop() {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *Processor) Enqueue(job Job) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return ErrStopped
+	}
+	select {
+	case p.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (p *Processor) handle(ctx context.Context, job Job) {
+	log := p.log.With(slog.String("media_id", job.MediaId.String()))
+	defer os.Remove(job.SpoolPath)
+
+	if err := p.mediaStore.SetMediaProcessing(ctx, job.MediaId); err != nil {
+		log.Error("failed to mark media as processing", sl.Error(err))
+	}
+
+	if err := p.process(ctx, job); err != nil {
+		log.Error("failed to process media", sl.Error(err))
+		// Ошибку записываем и при остановке процессора, иначе запись останется в processing
+		if err := p.mediaStore.FailMedia(context.WithoutCancel(ctx), job.MediaId, err.Error()); err != nil {
+			log.Error("failed to mark media as failed", sl.Error(err))
+		}
+		return
+	}
+	log.Info("media processed successfully")
+}
+
+func (p *Processor) process(ctx context.Context, job Job) error {
+	const op = "lib.imageproc.process"
+
+	data, err := os.ReadFile(job.SpoolPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Размеры читаются из заголовка до декодирования, чтобы маленький файл не развернулся в гигабайты пикселей
+	imgCfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if int64(imgCfg.Width)*int64(imgCfg.Height) > p.cfg.MaxPixels {
+		return fmt.Errorf("%s: %w: %dx%d", op, ErrTooLarge, imgCfg.Width, imgCfg.Height)
+	}
+
+	// Декодирование и повторное кодирование отбрасывают EXIF, в том числе GPS
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if job.ContentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	originalFormat := "image/png"
+	if job.ContentType == "image/jpeg" {
+		originalFormat = "image/jpeg"
+	}
+	original, err := p.store(ctx, job.KeyPrefix+"/original", img, originalFormat)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	original.Name = "original"
+
+	totalSize := original.Size
+	variants := []models.MediaVariant{original}
+	for _, spec := range p.variants {
+		// Не увеличиваем изображение, миниатюра создаётся всегда
+		if spec.maxSide <= 0 || (max(width, height) <= spec.maxSide && spec.name != "thumbnail") {
+			continue
+		}
+		resized := resize(img, spec.maxSide)
+
+		for _, format := range []string{"image/webp", "image/jpeg"} {
+			variant, err := p.store(ctx, job.KeyPrefix+"/"+spec.name, resized, format)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			variant.Name = spec.name
+			totalSize += variant.Size
+			variants = append(variants, variant)
+		}
+	}
+
+	if err := p.mediaStore.CompleteMedia(ctx, job.MediaId, width, height, original.URL, totalSize, variants); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (p *Processor) store(ctx context.Context, keyBase string, img image.Image, format string) (models.MediaVariant, error) {
+	var buf bytes.Buffer
+	var extension string
+	var err error
+
+	switch format {
+	case "image/jpeg":
+		extension = ".jpg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: p.cfg.JPEGQuality})
+	case "image/webp":
+		extension = ".webp"
+		err = nativewebp.Encode(&buf, img, nil)
+	default:
+		extension = ".png"
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return models.MediaVariant{}, err
+	}
+
+	key := keyBase + extension
+	size := int64(buf.Len())
+	if err := p.blobStore.Put(ctx, key, &buf, size, format); err != nil {
+		return models.MediaVariant{}, err
+	}
+
+	return models.MediaVariant{
+		ContentType: format,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Size:        size,
+		URL:         p.blobStore.URL(key),
+	}, nil
+}
+
+func resize(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if max(w, h) > maxSide {
+		if w >= h {
+			h = max(1, h*maxSide/w)
+			w = maxSide
+		} else {
+			w = max(1, w*maxSide/h)
+			h = maxSide
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
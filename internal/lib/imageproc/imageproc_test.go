@@ -0,0 +1,78 @@
+package imageproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"new_service/internal/config"
+	"new_service/internal/models"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type nopMediaStore struct{}
+
+func (nopMediaStore) SetMediaProcessing(ctx context.Context, mediaId uuid.UUID) error { return nil }
+
+func (nopMediaStore) CompleteMedia(ctx context.Context, mediaId uuid.UUID, width int, height int, url string, size int64, variants []models.MediaVariant) error {
+	return nil
+}
+
+func (nopMediaStore) FailMedia(ctx context.Context, mediaId uuid.UUID, reason string) error {
+	return nil
+}
+
+type nopBlobStore struct{}
+
+func (nopBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+func (nopBlobStore) URL(key string) string { return "/media/" + key }
+
+func newTestProcessor(cfg config.ImageProcessing) *Processor {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(log, cfg, nopMediaStore{}, nopBlobStore{})
+}
+
+func TestEnqueueAfterStop(t *testing.T) {
+	p := newTestProcessor(config.ImageProcessing{Workers: 1, QueueSize: 1, MaxPixels: 1 << 20})
+	p.Start(context.Background())
+	p.Stop()
+	// Повторная остановка не должна закрывать канал второй раз
+	p.Stop()
+
+	if err := p.Enqueue(Job{MediaId: uuid.New()}); !errors.Is(err, ErrStopped) {
+		t.Fatalf("Enqueue after Stop: got %v, want ErrStopped", err)
+	}
+}
+
+func TestProcessRejectsTooManyPixels(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 100, 100))); err != nil {
+		t.Fatal(err)
+	}
+	spool := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(spool, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	job := Job{MediaId: uuid.New(), KeyPrefix: "u/m", ContentType: "image/png", SpoolPath: spool}
+
+	p := newTestProcessor(config.ImageProcessing{MaxPixels: 100*100 - 1, JPEGQuality: 85, ThumbnailSize: 10})
+	if err := p.process(context.Background(), job); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("process: got %v, want ErrTooLarge", err)
+	}
+
+	p = newTestProcessor(config.ImageProcessing{MaxPixels: 100 * 100, JPEGQuality: 85, ThumbnailSize: 10})
+	if err := p.process(context.Background(), job); err != nil {
+		t.Fatalf("process at the limit: %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package sl
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+type loggerKey struct{}
+
+func WithLogger(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// FromContext возвращает логгер запроса, а вне запроса — fallback
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if log, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return log
+	}
+	return fallback
+}
+
+// With добавляет атрибуты к логгеру запроса, например user_id после авторизации
+func With(c *gin.Context, args ...any) {
+	log, ok := c.Request.Context().Value(loggerKey{}).(*slog.Logger)
+	if !ok {
+		return
+	}
+	c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), log.With(args...)))
+}
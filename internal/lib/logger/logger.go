@@ -3,6 +3,9 @@ package sl
 import "log/slog"
 
 func Error(err error) slog.Attr {
+	if err == nil {
+		return slog.String("error", "<nil>")
+	}
 	return slog.Attr{
 		Key:   "error",
 		Value: slog.StringValue(err.Error()),
@@ -0,0 +1,69 @@
+package sl
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestLogger присваивает запросу X-Request-ID, кладёт в контекст логгер запроса
+// и пишет одну строку access-лога после обработки
+func RequestLogger(log *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		reqLog := log.With(
+			slog.String("request_id", requestID),
+			slog.String("route", route),
+		)
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			reqLog = reqLog.With(slog.String("trace_id", span.TraceID().String()))
+		}
+		c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), reqLog))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		// Логгер берётся заново: после авторизации в нём уже есть user_id
+		FromContext(c.Request.Context(), reqLog).LogAttrs(c.Request.Context(), level, "request handled",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		)
+	}
+}
+
+// validRequestID принимает чужой идентификатор, только если его безопасно писать в логи
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "bloggery"
+
+// Registry — отдельный реестр, чтобы /metrics не зависел от глобального состояния библиотек
+var Registry = prometheus.NewRegistry()
+
+var (
+	PostsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "posts_created_total",
+		Help:      "Number of created posts.",
+	})
+
+	// Logins размечен способом входа (password, oidc) и результатом (success, failure)
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Number of login attempts.",
+	}, []string{"method", "result"})
+
+	// TokensRevoked размечен причиной: logout для одного токена, all_sessions для всех токенов пользователя
+	TokensRevoked = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_revoked_total",
+		Help:      "Number of token revocations.",
+	}, []string{"reason"})
+
+	// RateLimited размечен группой лимита и результатом: rejected или redis_error
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_events_total",
+		Help:      "Number of requests rejected by the rate limiter or passed through it on Redis errors.",
+	}, []string{"group", "result"})
+
+	// CacheRequests размечен кэшем (post, latest_posts) и результатом: local_hit, redis_hit или miss
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Number of cache lookups.",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		PostsCreated,
+		Logins,
+		TokensRevoked,
+		RateLimited,
+		CacheRequests,
+		httpRequests,
+		httpDuration,
+		redisDuration,
+	)
+}
+
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolAcquiredConns = poolDesc("acquired_conns", "Number of currently acquired connections.")
+	poolIdleConns     = poolDesc("idle_conns", "Number of currently idle connections.")
+	poolTotalConns    = poolDesc("total_conns", "Total number of connections in the pool.")
+	poolMaxConns      = poolDesc("max_conns", "Maximum size of the pool.")
+	poolAcquireCount  = poolDesc("acquire_total", "Number of successful connection acquires.")
+	poolAcquireWait   = poolDesc("acquire_wait_seconds_total", "Total time spent waiting for a connection.")
+	poolEmptyAcquire  = poolDesc("empty_acquire_total", "Number of acquires that had to wait for a connection.")
+	poolCanceled      = poolDesc("canceled_acquire_total", "Number of acquires canceled by context.")
+)
+
+func poolDesc(name string, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "pgxpool", name), help, nil, nil)
+}
+
+// poolCollector снимает статистику пула в момент сбора метрик
+type poolCollector struct {
+	pool *pgxpool.Pool
+}
+
+func RegisterPool(pool *pgxpool.Pool) {
+	Registry.MustRegister(&poolCollector{pool: pool})
+}
+
+func (pc *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredConns
+	ch <- poolIdleConns
+	ch <- poolTotalConns
+	ch <- poolMaxConns
+	ch <- poolAcquireCount
+	ch <- poolAcquireWait
+	ch <- poolEmptyAcquire
+	ch <- poolCanceled
+}
+
+func (pc *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := pc.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireWait, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquire, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceled, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var redisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "redis_command_duration_seconds",
+	Help:      "Redis command latency.",
+	Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+}, []string{"command", "status"})
+
+// RedisHook замеряет время команд; redis.Nil считается успешным ответом
+type RedisHook struct{}
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := next(ctx, network, addr)
+		redisDuration.WithLabelValues("dial", redisStatus(err)).Observe(time.Since(start).Seconds())
+		return conn, err
+	}
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		redisDuration.WithLabelValues(cmd.Name(), redisStatus(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		redisDuration.WithLabelValues("pipeline", redisStatus(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+func redisStatus(err error) string {
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,144 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"new_service/internal/config"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrInvalidState = errors.New("invalid or expired oidc state")
+	ErrInvalidNonce = errors.New("invalid oidc nonce")
+	ErrNoIDToken    = errors.New("no id_token in token response")
+)
+
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type Provider struct {
+	issuer   string
+	oauth2   oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+	rdb      *redis.Client
+	stateTTL time.Duration
+}
+
+type pendingLogin struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// New выполняет discovery по issuer_url, поэтому для локальной разработки
+// достаточно указать адрес любого mock-issuer'а
+func New(ctx context.Context, cfg config.OIDC, rdb *redis.Client) (*Provider, error) {
+	const op = "lib.oidc.New"
+
+	provider, err := gooidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Provider{
+		issuer: cfg.IssuerURL,
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       cfg.Scopes,
+		},
+		verifier: provider.Verifier(&gooidc.Config{ClientID: cfg.ClientID}),
+		rdb:      rdb,
+		stateTTL: cfg.StateTTL,
+	}, nil
+}
+
+// AuthCodeURL сохраняет state, nonce и PKCE verifier в Redis до возврата пользователя.
+// State возвращается отдельно, чтобы привязать вход к браузеру, который его начал
+func (p *Provider) AuthCodeURL(ctx context.Context) (string, string, error) {
+	const op = "lib.oidc.AuthCodeURL"
+
+	state := oauth2.GenerateVerifier()
+	pending := pendingLogin{
+		Nonce:    oauth2.GenerateVerifier(),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err := p.rdb.Set(ctx, stateKey(state), data, p.stateTTL).Err(); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p.oauth2.AuthCodeURL(state,
+		gooidc.Nonce(pending.Nonce),
+		oauth2.S256ChallengeOption(pending.Verifier),
+	), state, nil
+}
+
+func (p *Provider) Exchange(ctx context.Context, state string, code string) (*Identity, error) {
+	const op = "lib.oidc.Exchange"
+
+	data, err := p.rdb.GetDel(ctx, stateKey(state)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrInvalidState
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var pending pendingLogin
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(pending.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrNoIDToken
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if idToken.Nonce != pending.Nonce {
+		return nil, ErrInvalidNonce
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Identity{
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+func stateKey(state string) string {
+	return "oidc_state:" + state
+}
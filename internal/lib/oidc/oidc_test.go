@@ -0,0 +1,327 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"new_service/internal/config"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	testClientID = "bloggery"
+	testKeyID    = "test-key"
+)
+
+// grant — выданный mock-issuer'ом код авторизации
+type grant struct {
+	challenge string
+	claims    jwt.MapClaims
+	// key подписывает id_token; nil — ключ issuer'а
+	key *rsa.PrivateKey
+	// noIDToken убирает id_token из ответа token endpoint
+	noIDToken bool
+}
+
+// mockIssuer — минимальный OpenID провайдер: discovery, JWKS и token endpoint с проверкой PKCE
+type mockIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	issuer := &mockIssuer{key: newKey(t), grants: make(map[string]grant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("GET /jwks", issuer.jwks)
+	mux.HandleFunc("POST /token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// authorize выдаёт код так, как это сделал бы провайдер после входа пользователя
+func (m *mockIssuer) authorize(challenge string, claims jwt.MapClaims) string {
+	return m.authorizeGrant(grant{challenge: challenge, claims: claims})
+}
+
+func (m *mockIssuer) authorizeGrant(g grant) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code := rand.Text()
+	m.grants[code] = g
+	return code
+}
+
+// claims — стандартные утверждения id_token для пользователя subject
+func (m *mockIssuer) claims(subject string, nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            m.server.URL,
+		"aud":            testClientID,
+		"sub":            subject,
+		"nonce":          nonce,
+		"email":          subject + "@example.com",
+		"email_verified": true,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func (m *mockIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                m.server.URL,
+		"authorization_endpoint":                m.server.URL + "/authorize",
+		"token_endpoint":                        m.server.URL + "/token",
+		"jwks_uri":                              m.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (m *mockIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	m.mu.Lock()
+	g, ok := m.grants[r.PostForm.Get("code")]
+	delete(m.grants, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if challenge(r.PostForm.Get("code_verifier")) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	body := map[string]any{"access_token": rand.Text(), "token_type": "Bearer", "expires_in": 3600}
+	if !g.noIDToken {
+		key := g.key
+		if key == nil {
+			key = m.key
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, g.claims)
+		idToken.Header["kid"] = testKeyID
+		signed, err := idToken.SignedString(key)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+			return
+		}
+		body["id_token"] = signed
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func newTestProvider(t *testing.T, issuer *mockIssuer) *Provider {
+	t.Helper()
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	provider, err := New(context.Background(), config.OIDC{
+		IssuerURL:    issuer.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/auth/oidc/callback",
+		Scopes:       []string{"openid", "email"},
+		StateTTL:     time.Minute,
+	}, rdb)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return provider
+}
+
+// login — параметры, которые провайдер получил бы из AuthCodeURL
+type login struct {
+	state     string
+	nonce     string
+	challenge string
+}
+
+func startLogin(t *testing.T, provider *Provider) login {
+	t.Helper()
+
+	authURL, state, err := provider.AuthCodeURL(context.Background())
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth url: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("state") != state {
+		t.Fatalf("state in url = %q, returned %q", query.Get("state"), state)
+	}
+	if method := query.Get("code_challenge_method"); method != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", method)
+	}
+	return login{state: query.Get("state"), nonce: query.Get("nonce"), challenge: query.Get("code_challenge")}
+}
+
+func TestExchange(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := newTestProvider(t, issuer)
+
+	login := startLogin(t, provider)
+	code := issuer.authorize(login.challenge, issuer.claims("alice", login.nonce))
+
+	identity, err := provider.Exchange(context.Background(), login.state, code)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := Identity{Issuer: issuer.server.URL, Subject: "alice", Email: "alice@example.com", EmailVerified: true}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+
+	// state одноразовый: повторный callback не должен пройти
+	_, err = provider.Exchange(context.Background(), login.state, code)
+	if !errors.Is(err, ErrInvalidState) {
+		t.Errorf("second Exchange: err = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestExchangeUnknownState(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := newTestProvider(t, issuer)
+
+	login := startLogin(t, provider)
+	code := issuer.authorize(login.challenge, issuer.claims("alice", login.nonce))
+
+	_, err := provider.Exchange(context.Background(), "forged-state", code)
+	if !errors.Is(err, ErrInvalidState) {
+		t.Errorf("err = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestExchangePKCEMismatch(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := newTestProvider(t, issuer)
+
+	// Код выдан для чужого входа: verifier из нашего state не соответствует его challenge
+	victim := startLogin(t, provider)
+	attacker := startLogin(t, provider)
+	code := issuer.authorize(attacker.challenge, issuer.claims("alice", victim.nonce))
+
+	_, err := provider.Exchange(context.Background(), victim.state, code)
+	if err == nil {
+		t.Fatal("Exchange succeeded with a code issued for another PKCE challenge")
+	}
+	if errors.Is(err, ErrInvalidState) || errors.Is(err, ErrInvalidNonce) {
+		t.Errorf("err = %v, want token endpoint error", err)
+	}
+}
+
+func TestExchangeNonceMismatch(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := newTestProvider(t, issuer)
+
+	login := startLogin(t, provider)
+	other := startLogin(t, provider)
+	code := issuer.authorize(login.challenge, issuer.claims("alice", other.nonce))
+
+	_, err := provider.Exchange(context.Background(), login.state, code)
+	if !errors.Is(err, ErrInvalidNonce) {
+		t.Errorf("err = %v, want ErrInvalidNonce", err)
+	}
+}
+
+func TestExchangeInvalidIDToken(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := newTestProvider(t, issuer)
+
+	tests := []struct {
+		name   string
+		modify func(g *grant)
+	}{
+		{name: "foreign key", modify: func(g *grant) { g.key = newKey(t) }},
+		{name: "wrong audience", modify: func(g *grant) { g.claims["aud"] = "another-client" }},
+		{name: "wrong issuer", modify: func(g *grant) { g.claims["iss"] = "https://evil.example.com" }},
+		{name: "expired", modify: func(g *grant) { g.claims["exp"] = time.Now().Add(-time.Minute).Unix() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			login := startLogin(t, provider)
+			g := grant{challenge: login.challenge, claims: issuer.claims("alice", login.nonce)}
+			tt.modify(&g)
+
+			identity, err := provider.Exchange(context.Background(), login.state, issuer.authorizeGrant(g))
+			if err == nil {
+				t.Fatalf("Exchange accepted an invalid id_token: %+v", *identity)
+			}
+		})
+	}
+}
+
+func TestExchangeNoIDToken(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := newTestProvider(t, issuer)
+
+	login := startLogin(t, provider)
+	code := issuer.authorizeGrant(grant{challenge: login.challenge, noIDToken: true})
+
+	_, err := provider.Exchange(context.Background(), login.state, code)
+	if !errors.Is(err, ErrNoIDToken) {
+		t.Errorf("err = %v, want ErrNoIDToken", err)
+	}
+}
+
+func newKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,145 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"new_service/internal/config"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	saltLength  uint32
+	keyLength   uint32
+}
+
+type Hasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     argon2Params
+}
+
+func NewHasher(cfg config.PasswordHashing) (*Hasher, error) {
+	const op = "lib.password.NewHasher"
+
+	if cfg.Algorithm != AlgorithmBcrypt && cfg.Algorithm != AlgorithmArgon2id {
+		return nil, fmt.Errorf("%s: unsupported algorithm %q", op, cfg.Algorithm)
+	}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("%s: bcrypt cost %d out of range", op, cfg.BcryptCost)
+	}
+
+	return &Hasher{
+		algorithm:  cfg.Algorithm,
+		bcryptCost: cfg.BcryptCost,
+		argon2: argon2Params{
+			memory:      cfg.Argon2Memory,
+			iterations:  cfg.Argon2Iterations,
+			parallelism: cfg.Argon2Parallelism,
+			saltLength:  cfg.Argon2SaltLength,
+			keyLength:   cfg.Argon2KeyLength,
+		},
+	}, nil
+}
+
+// Hash возвращает хэш в самоописывающем формате: параметры алгоритма хранятся вместе с хэшем
+func (h *Hasher) Hash(password string) (string, error) {
+	const op = "lib.password.Hash"
+
+	if h.algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, h.argon2.saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	key := argon2.IDKey([]byte(password), salt, h.argon2.iterations, h.argon2.memory, h.argon2.parallelism, h.argon2.keyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.argon2.memory, h.argon2.iterations, h.argon2.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify проверяет пароль и сообщает, нужно ли пересчитать хэш под текущие настройки
+func (h *Hasher) Verify(hash string, password string) (bool, bool, error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return h.verifyArgon2id(hash, password)
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false, false, ErrUnknownHash
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return false, false, nil
+	}
+
+	needsRehash := h.algorithm != AlgorithmBcrypt || cost < h.bcryptCost
+	return true, needsRehash, nil
+}
+
+func (h *Hasher) verifyArgon2id(hash string, password string) (bool, bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrUnknownHash
+	}
+
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return false, false, ErrUnknownHash
+	}
+	// argon2.IDKey паникует на нулевых параметрах
+	if params.iterations == 0 || params.parallelism == 0 {
+		return false, false, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, ErrUnknownHash
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash := h.algorithm != AlgorithmArgon2id ||
+		params.memory < h.argon2.memory ||
+		params.iterations < h.argon2.iterations ||
+		params.parallelism < h.argon2.parallelism ||
+		uint32(len(salt)) < h.argon2.saltLength ||
+		uint32(len(key)) < h.argon2.keyLength
+	return true, needsRehash, nil
+}
@@ -0,0 +1,167 @@
+package password
+
+import (
+	"errors"
+	"new_service/internal/config"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Параметры уменьшены, чтобы тесты не тратили время на вычисление хэшей
+var testHashing = config.PasswordHashing{
+	Algorithm:         AlgorithmArgon2id,
+	BcryptCost:        bcrypt.MinCost,
+	Argon2Memory:      64,
+	Argon2Iterations:  1,
+	Argon2Parallelism: 1,
+	Argon2SaltLength:  16,
+	Argon2KeyLength:   32,
+}
+
+func newTestHasher(t *testing.T, modify func(cfg *config.PasswordHashing)) *Hasher {
+	t.Helper()
+	cfg := testHashing
+	if modify != nil {
+		modify(&cfg)
+	}
+	h, err := NewHasher(cfg)
+	if err != nil {
+		t.Fatalf("NewHasher: %v", err)
+	}
+	return h
+}
+
+func hash(t *testing.T, h *Hasher, password string) string {
+	t.Helper()
+	hashed, err := h.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	return hashed
+}
+
+func TestHashVerify(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmArgon2id, AlgorithmBcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			h := newTestHasher(t, func(cfg *config.PasswordHashing) { cfg.Algorithm = algorithm })
+			hashed := hash(t, h, "correct horse")
+
+			ok, needsRehash, err := h.Verify(hashed, "correct horse")
+			if err != nil || !ok || needsRehash {
+				t.Errorf("Verify(correct) = (%v, %v, %v), want (true, false, nil)", ok, needsRehash, err)
+			}
+
+			ok, _, err = h.Verify(hashed, "wrong horse")
+			if err != nil || ok {
+				t.Errorf("Verify(wrong) = (%v, %v), want (false, nil)", ok, err)
+			}
+		})
+	}
+
+	// Соль случайная, поэтому одинаковые пароли дают разные хэши
+	h := newTestHasher(t, nil)
+	if hash(t, h, "password") == hash(t, h, "password") {
+		t.Error("two argon2id hashes of the same password are equal")
+	}
+}
+
+func TestVerifyMalformedHash(t *testing.T) {
+	h := newTestHasher(t, nil)
+	valid := hash(t, h, "password")
+	parts := strings.Split(valid, "$")
+	salt, key := parts[4], parts[5]
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"empty", ""},
+		{"plain text", "password"},
+		{"unknown algorithm", "$scrypt$ln=15,r=8,p=1$" + salt + "$" + key},
+		{"missing key", "$argon2id$v=19$m=64,t=1,p=1$" + salt},
+		{"extra section", valid + "$extra"},
+		{"unsupported version", "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key},
+		{"malformed version", "$argon2id$version$m=64,t=1,p=1$" + salt + "$" + key},
+		{"malformed parameters", "$argon2id$v=19$m=64;t=1;p=1$" + salt + "$" + key},
+		{"parallelism out of range", "$argon2id$v=19$m=64,t=1,p=256$" + salt + "$" + key},
+		{"zero iterations", "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key},
+		{"zero parallelism", "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key},
+		{"salt is not base64", "$argon2id$v=19$m=64,t=1,p=1$!!!$" + key},
+		{"key is not base64", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$!!!"},
+		{"empty key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$"},
+		{"truncated bcrypt", "$2a$04$short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, _, err := h.Verify(tt.hash, "password")
+			if !errors.Is(err, ErrUnknownHash) {
+				t.Errorf("err = %v, want ErrUnknownHash", err)
+			}
+			if ok {
+				t.Error("malformed hash verified")
+			}
+		})
+	}
+}
+
+func TestVerifyNeedsRehash(t *testing.T) {
+	tests := []struct {
+		name    string
+		stored  func(cfg *config.PasswordHashing)
+		current func(cfg *config.PasswordHashing)
+		want    bool
+	}{
+		{"same argon2id parameters", nil, nil, false},
+		{"bcrypt to argon2id", func(cfg *config.PasswordHashing) { cfg.Algorithm = AlgorithmBcrypt }, nil, true},
+		{"argon2id to bcrypt", nil, func(cfg *config.PasswordHashing) { cfg.Algorithm = AlgorithmBcrypt }, true},
+		{"weaker bcrypt cost", func(cfg *config.PasswordHashing) { cfg.Algorithm = AlgorithmBcrypt }, func(cfg *config.PasswordHashing) {
+			cfg.Algorithm = AlgorithmBcrypt
+			cfg.BcryptCost = bcrypt.MinCost + 1
+		}, true},
+		{"stronger bcrypt cost", func(cfg *config.PasswordHashing) {
+			cfg.Algorithm = AlgorithmBcrypt
+			cfg.BcryptCost = bcrypt.MinCost + 1
+		}, func(cfg *config.PasswordHashing) { cfg.Algorithm = AlgorithmBcrypt }, false},
+		{"less memory", nil, func(cfg *config.PasswordHashing) { cfg.Argon2Memory = 128 }, true},
+		{"fewer iterations", nil, func(cfg *config.PasswordHashing) { cfg.Argon2Iterations = 2 }, true},
+		{"fewer threads", nil, func(cfg *config.PasswordHashing) { cfg.Argon2Parallelism = 2 }, true},
+		{"shorter salt", nil, func(cfg *config.PasswordHashing) { cfg.Argon2SaltLength = 32 }, true},
+		{"shorter key", nil, func(cfg *config.PasswordHashing) { cfg.Argon2KeyLength = 64 }, true},
+		{"more memory than configured", func(cfg *config.PasswordHashing) { cfg.Argon2Memory = 128 }, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashed := hash(t, newTestHasher(t, tt.stored), "password")
+
+			ok, needsRehash, err := newTestHasher(t, tt.current).Verify(hashed, "password")
+			if err != nil || !ok {
+				t.Fatalf("Verify = (%v, %v), want (true, nil)", ok, err)
+			}
+			if needsRehash != tt.want {
+				t.Errorf("needsRehash = %v, want %v", needsRehash, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHasherRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *config.PasswordHashing)
+	}{
+		{"unknown algorithm", func(cfg *config.PasswordHashing) { cfg.Algorithm = "md5" }},
+		{"bcrypt cost too low", func(cfg *config.PasswordHashing) { cfg.BcryptCost = bcrypt.MinCost - 1 }},
+		{"bcrypt cost too high", func(cfg *config.PasswordHashing) { cfg.BcryptCost = bcrypt.MaxCost + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testHashing
+			tt.modify(&cfg)
+			if _, err := NewHasher(cfg); err == nil {
+				t.Error("NewHasher accepted an invalid config")
+			}
+		})
+	}
+}
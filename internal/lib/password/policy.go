@@ -0,0 +1,71 @@
+package password
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"new_service/internal/config"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrTooShort = errors.New("password is too short")
+	ErrTooLong  = errors.New("password is too long")
+	ErrBreached = errors.New("password has appeared in a data breach")
+)
+
+type Policy struct {
+	minLength int
+	maxLength int
+	breached  map[string]struct{}
+}
+
+// NewPolicy загружает список скомпрометированных паролей: по одному паролю на строку
+func NewPolicy(cfg config.PasswordPolicy) (*Policy, error) {
+	const op = "lib.password.NewPolicy"
+
+	policy := &Policy{
+		minLength: cfg.MinLength,
+		maxLength: cfg.MaxLength,
+		breached:  make(map[string]struct{}),
+	}
+
+	if cfg.BreachedListPath == "" {
+		return policy, nil
+	}
+
+	file, err := os.Open(cfg.BreachedListPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			policy.breached[line] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return policy, nil
+}
+
+func (p *Policy) Validate(password string) error {
+	if utf8.RuneCountInString(password) < p.minLength {
+		return fmt.Errorf("%w: minimum length is %d", ErrTooShort, p.minLength)
+	}
+	// bcrypt учитывает только первые 72 байта
+	if p.maxLength > 0 && len(password) > p.maxLength {
+		return fmt.Errorf("%w: maximum length is %d bytes", ErrTooLong, p.maxLength)
+	}
+	if _, ok := p.breached[password]; ok {
+		return ErrBreached
+	}
+	return nil
+}
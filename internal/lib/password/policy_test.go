@@ -0,0 +1,62 @@
+package password
+
+import (
+	"errors"
+	"new_service/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(list, []byte("password123\n\n  qwertyuiop  \n"), 0o600); err != nil {
+		t.Fatalf("write breached list: %v", err)
+	}
+	policy, err := NewPolicy(config.PasswordPolicy{MinLength: 8, MaxLength: 72, BreachedListPath: list})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     error
+	}{
+		{"valid", "correct horse", nil},
+		{"too short", "short", ErrTooShort},
+		{"exactly the minimum", "12345678", nil},
+		{"minimum counted in characters", "пароль12", nil},
+		{"exactly the maximum", strings.Repeat("a", 72), nil},
+		{"too long", strings.Repeat("a", 73), ErrTooLong},
+		{"maximum counted in bytes", strings.Repeat("я", 37), ErrTooLong},
+		{"breached", "password123", ErrBreached},
+		{"breached with spaces in the list", "qwertyuiop", ErrBreached},
+		{"breached password is case sensitive", "Password123", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := policy.Validate(tt.password); !errors.Is(err, tt.want) {
+				t.Errorf("Validate(%q) = %v, want %v", tt.password, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyWithoutLimits(t *testing.T) {
+	policy, err := NewPolicy(config.PasswordPolicy{})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	if err := policy.Validate(strings.Repeat("a", 1000)); err != nil {
+		t.Errorf("Validate without max length: %v", err)
+	}
+}
+
+func TestNewPolicyMissingList(t *testing.T) {
+	_, err := NewPolicy(config.PasswordPolicy{BreachedListPath: filepath.Join(t.TempDir(), "missing.txt")})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("err = %v, want os.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"new_service/internal/config"
+	"new_service/internal/lib/apperr"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "ratelimit"
+
+// Policy — не больше Requests запросов за Period на одну личность; весь лимит можно израсходовать сразу
+type Policy struct {
+	Name     string
+	Requests int
+	Period   time.Duration
+}
+
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter — через сколько будет разрешён следующий запрос, если этот отклонён
+	RetryAfter time.Duration
+	// ResetAfter — через сколько лимит восстановится полностью
+	ResetAfter time.Duration
+}
+
+// gcra — token bucket в форме GCRA: в ключе хранится теоретическое время прихода
+// следующего запроса (TAT), поэтому на личность нужен один ключ и один вызов.
+// Время берётся из Redis, чтобы инстансы с разными часами считали одинаково.
+var gcra = redis.NewScript(`
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local interval = period / limit
+
+local tat = tonumber(redis.call("GET", key)) or now
+tat = math.max(tat, now)
+
+local new_tat = tat + interval
+local diff = now - (new_tat - period)
+if diff < 0 then
+	return {0, 0, math.ceil(-diff), math.ceil(tat - now)}
+end
+
+redis.call("SET", key, new_tat, "PX", math.ceil(new_tat - now))
+return {1, math.floor(diff / interval), 0, math.ceil(new_tat - now)}
+`)
+
+type Limiter struct {
+	rdb *redis.Client
+	cfg config.RateLimit
+	log *slog.Logger
+}
+
+func New(log *slog.Logger, rdb *redis.Client, cfg config.RateLimit) *Limiter {
+	return &Limiter{rdb: rdb, cfg: cfg, log: log}
+}
+
+// Allow учитывает запрос личности identity в лимите policy
+func (l *Limiter) Allow(ctx context.Context, policy Policy, identity string) (Result, error) {
+	const op = "lib.ratelimit.Allow"
+
+	key := fmt.Sprintf("%s:%s:%s", keyPrefix, policy.Name, identity)
+	values, err := gcra.Run(ctx, l.rdb, []string{key}, policy.Requests, policy.Period.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+// Middleware ограничивает запросы по user_id, если он уже выставлен JWT-мидлварой, иначе по IP
+func (l *Limiter) Middleware(policy Policy) gin.HandlerFunc {
+	if l.cfg.Disabled || policy.Requests <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	policyHeader := fmt.Sprintf("%d;w=%d", policy.Requests, int(math.Ceil(policy.Period.Seconds())))
+
+	return func(c *gin.Context) {
+		result, err := l.Allow(c.Request.Context(), policy, identity(c))
+		if err != nil {
+			log := sl.FromContext(c.Request.Context(), l.log)
+			metrics.RateLimited.WithLabelValues(policy.Name, "redis_error").Inc()
+			if l.cfg.FailClosed {
+				c.Error(apperr.Unavailable("rate limiter is unavailable", err))
+				c.Abort()
+				return
+			}
+			log.Warn("rate limiter is unavailable, request allowed", slog.String("group", policy.Name), sl.Error(err))
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policyHeader)
+		c.Header("RateLimit-Limit", strconv.Itoa(policy.Requests))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(seconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			metrics.RateLimited.WithLabelValues(policy.Name, "rejected").Inc()
+			c.Error(apperr.RateLimited("too many requests, try again later", result.RetryAfter))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ByMethod применяет read к безопасным методам, а write — к остальным
+func (l *Limiter) ByMethod(read Policy, write Policy) gin.HandlerFunc {
+	limitRead, limitWrite := l.Middleware(read), l.Middleware(write)
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			limitRead(c)
+		default:
+			limitWrite(c)
+		}
+	}
+}
+
+func identity(c *gin.Context) string {
+	if userId := c.GetString("user_id"); userId != "" {
+		return "user:" + userId
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"new_service/internal/config"
+	"new_service/internal/lib/apperr"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestLimiter(t *testing.T) (*Limiter, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	// Скрипт берёт время из Redis, поэтому часы miniredis фиксируем
+	mr.SetTime(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(log, rdb, config.RateLimit{}), mr
+}
+
+func TestAllow(t *testing.T) {
+	limiter, mr := newTestLimiter(t)
+	ctx := context.Background()
+	policy := Policy{Name: "test", Requests: 3, Period: 3 * time.Second}
+
+	// Весь лимит доступен сразу, каждый запрос отодвигает полное восстановление на Period/Requests
+	for i, want := range []Result{
+		{Allowed: true, Remaining: 2, ResetAfter: time.Second},
+		{Allowed: true, Remaining: 1, ResetAfter: 2 * time.Second},
+		{Allowed: true, Remaining: 0, ResetAfter: 3 * time.Second},
+		{Allowed: false, Remaining: 0, RetryAfter: time.Second, ResetAfter: 3 * time.Second},
+	} {
+		got, err := limiter.Allow(ctx, policy, "ip:192.0.2.1")
+		if err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+		if got != want {
+			t.Errorf("request %d: got %+v, want %+v", i+1, got, want)
+		}
+	}
+
+	// Другая личность считается отдельно
+	got, err := limiter.Allow(ctx, policy, "ip:192.0.2.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Allowed || got.Remaining != 2 {
+		t.Errorf("other identity: got %+v, want allowed with 2 remaining", got)
+	}
+
+	// Через Period/Requests восстанавливается один запрос
+	mr.SetTime(time.Date(2026, 1, 1, 0, 0, 1, 0, time.UTC))
+	got, err = limiter.Allow(ctx, policy, "ip:192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Result{Allowed: true, Remaining: 0, ResetAfter: 3 * time.Second}
+	if got != want {
+		t.Errorf("after 1s: got %+v, want %+v", got, want)
+	}
+
+	// После полного Period лимит снова целый
+	mr.SetTime(time.Date(2026, 1, 1, 0, 0, 10, 0, time.UTC))
+	got, err = limiter.Allow(ctx, policy, "ip:192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = Result{Allowed: true, Remaining: 2, ResetAfter: time.Second}
+	if got != want {
+		t.Errorf("after full period: got %+v, want %+v", got, want)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter, _ := newTestLimiter(t)
+
+	router := gin.New()
+	router.Use(apperr.Middleware(limiter.log))
+	router.GET("/", limiter.Middleware(Policy{Name: "test", Requests: 2, Period: time.Minute}), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for i, want := range []struct {
+		status     int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{status: http.StatusNoContent, remaining: "1", reset: "30"},
+		{status: http.StatusNoContent, remaining: "0", reset: "60"},
+		{status: http.StatusTooManyRequests, remaining: "0", reset: "60", retryAfter: "30"},
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rec.Code != want.status {
+			t.Errorf("request %d: status = %d, want %d", i+1, rec.Code, want.status)
+		}
+		if got := rec.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+			t.Errorf("request %d: RateLimit-Policy = %q, want %q", i+1, got, "2;w=60")
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != want.remaining {
+			t.Errorf("request %d: RateLimit-Remaining = %q, want %q", i+1, got, want.remaining)
+		}
+		if got := rec.Header().Get("RateLimit-Reset"); got != want.reset {
+			t.Errorf("request %d: RateLimit-Reset = %q, want %q", i+1, got, want.reset)
+		}
+		if got := rec.Header().Get("Retry-After"); got != want.retryAfter {
+			t.Errorf("request %d: Retry-After = %q, want %q", i+1, got, want.retryAfter)
+		}
+	}
+}
@@ -1,20 +1,42 @@
 package response
 
-type Response struct {
-	Status  string `json:"status" env-required:"true"`
-	Message string `json:"message" env-required:"true"`
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Envelope — общий формат успешных ответов. Ошибки отдаются отдельно в формате problem+json (apperr)
+type Envelope struct {
+	Data any   `json:"data"`
+	Meta *Meta `json:"meta,omitempty"`
+}
+
+// Meta описывает страницу списка, полученного по курсору
+type Meta struct {
+	Limit      int        `json:"limit"`
+	Count      int        `json:"count"`
+	NextCursor *time.Time `json:"next_cursor,omitempty"`
+}
+
+type Message struct {
+	Message string `json:"message"`
+}
+
+func JSON(c *gin.Context, status int, data any) {
+	c.JSON(status, Envelope{Data: data})
+}
+
+func OK(c *gin.Context, data any) {
+	JSON(c, http.StatusOK, data)
 }
 
-func OK(msg string) *Response {
-	return &Response{
-		Status:  "OK",
-		Message: msg,
-	}
+// Text отвечает сообщением для действий, которым нечего вернуть
+func Text(c *gin.Context, status int, message string) {
+	JSON(c, status, Message{Message: message})
 }
 
-func Error(err error) *Response {
-	return &Response{
-		Status:  "Error",
-		Message: err.Error(),
-	}
+func Page(c *gin.Context, data any, meta Meta) {
+	c.JSON(http.StatusOK, Envelope{Data: data, Meta: &meta})
 }
@@ -0,0 +1,87 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"new_service/internal/lib/apperr"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var usernameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_]{3,32}$`)
+
+// init настраивает валидатор gin: имена полей берутся из json/form тегов, добавляется правило username
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+
+	_ = v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernameRegexp.MatchString(fl.Field().String())
+	})
+}
+
+// Error переводит ошибку биндинга в ошибку валидации с сообщениями по полям
+func Error(err error) error {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		fields := make(map[string]string, len(validationErrors))
+		for _, fieldError := range validationErrors {
+			fields[fieldError.Field()] = message(fieldError)
+		}
+		return apperr.Validation("invalid request", fields)
+	}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) && typeError.Field != "" {
+		return apperr.Validation("invalid request", map[string]string{
+			typeError.Field: fmt.Sprintf("must be %s", typeError.Type.Kind()),
+		})
+	}
+
+	return apperr.Invalid("malformed request")
+}
+
+func message(fieldError validator.FieldError) string {
+	unit := ""
+	if fieldError.Kind() == reflect.String {
+		unit = " characters"
+	}
+
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return fmt.Sprintf("is required when %s is not provided", strings.ToLower(fieldError.Param()))
+	case "email":
+		return "must be a valid email"
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", fieldError.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", fieldError.Param(), unit)
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fieldError.Param(), " ", ", ")
+	case "username":
+		return "must be 3-32 characters: letters, digits or underscore"
+	case "http_url":
+		return "must be an http(s) URL"
+	default:
+		return "is invalid"
+	}
+}
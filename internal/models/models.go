@@ -17,6 +17,81 @@ type DbPost struct {
 	UserId    uuid.UUID `json:"user_id" env-required:"true"`
 	Title     string    `json:"title" env-required:"true"`
 	Content   string    `json:"content"`
+	Hidden    bool      `json:"hidden"`
 	UpdatedAt time.Time `json:"updated_at"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+type User struct {
+	UserId              uuid.UUID  `json:"user_id"`
+	Email               string     `json:"email"`
+	Username            *string    `json:"username"`
+	DisplayName         *string    `json:"display_name"`
+	Bio                 *string    `json:"bio"`
+	Website             *string    `json:"website"`
+	AvatarURL           *string    `json:"avatar_url"`
+	Role                string     `json:"role"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+type ExternalIdentity struct {
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Email     *string   `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PublicProfile struct {
+	Username       string    `json:"username"`
+	DisplayName    *string   `json:"display_name"`
+	Bio            *string   `json:"bio"`
+	Website        *string   `json:"website"`
+	AvatarURL      *string   `json:"avatar_url"`
+	PostsCount     int64     `json:"posts_count"`
+	FollowersCount int64     `json:"followers_count"`
+	FollowingCount int64     `json:"following_count"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ProfileUpdate содержит только изменяемые поля; nil означает "не менять", пустая строка - "очистить"
+type ProfileUpdate struct {
+	Username    *string
+	DisplayName *string
+	Bio         *string
+	Website     *string
+	AvatarURL   *string
+}
+
+const (
+	MediaPending    = "pending"
+	MediaProcessing = "processing"
+	MediaReady      = "ready"
+	MediaFailed     = "failed"
+)
+
+type Media struct {
+	MediaId     uuid.UUID      `json:"media_id"`
+	UserId      uuid.UUID      `json:"user_id"`
+	StorageKey  string         `json:"-"`
+	ContentType string         `json:"content_type"`
+	Size        int64          `json:"size"`
+	URL         string         `json:"url"`
+	Status      string         `json:"status"`
+	Width       *int           `json:"width"`
+	Height      *int           `json:"height"`
+	Variants    []MediaVariant `json:"variants"`
+	Error       *string        `json:"error,omitempty"`
+	ProcessedAt *time.Time     `json:"processed_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+type MediaVariant struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+}
@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"new_service/internal/config"
+	"new_service/internal/handlers/structs"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/metrics"
+	"new_service/internal/models"
+	"new_service/internal/repository/storage"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	keyPrefix = "cache"
+	// latestSize совпадает с max для limit в structs.PaginationParams, поэтому любая первая страница помещается в кэш
+	latestSize = 5
+)
+
+// setIfCurrent записывает значение, только если поколение ключа не менялось с начала загрузки.
+// Иначе загрузка могла прочитать базу до записи, а инвалидация уже прошла, и старое значение жило бы весь TTL
+var setIfCurrent = redis.NewScript(`
+local generation = redis.call("GET", KEYS[2]) or ""
+if generation ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1
+`)
+
+// latestCursor позже любого поста, с ним GetNextPosts возвращает самые новые посты
+var latestCursor = time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Storage — read-through кэш постов поверх storage.Storage: сначала LRU процесса, затем Redis, затем Postgres.
+// Записи через Storage сбрасывают затронутые ключи, остальные изменения (например, удаление аккаунта) видны через TTL
+type Storage struct {
+	db    *storage.Storage
+	rdb   *redis.Client
+	local *expirable.LRU[string, []byte]
+	group singleflight.Group
+	cfg   config.Cache
+	log   *slog.Logger
+}
+
+func New(log *slog.Logger, db *storage.Storage, rdb *redis.Client, cfg config.Cache) *Storage {
+	s := &Storage{db: db, rdb: rdb, cfg: cfg, log: log}
+	if cfg.LocalSize > 0 {
+		s.local = expirable.NewLRU[string, []byte](cfg.LocalSize, nil, cfg.LocalTTL)
+	}
+	return s
+}
+
+func (s *Storage) GetPost(ctx context.Context, postId uuid.UUID) (models.DbPost, error) {
+	if s.cfg.Disabled {
+		return s.db.GetPost(ctx, postId)
+	}
+
+	var post models.DbPost
+	err := s.readThrough(ctx, "post", postKey(postId), &post, func(ctx context.Context) (any, error) {
+		return s.db.GetPost(ctx, postId)
+	})
+	return post, err
+}
+
+// GetNextPosts отдаёт первую страницу из кэша последних постов пользователя, остальные страницы — из базы
+func (s *Storage) GetNextPosts(ctx context.Context, userId uuid.UUID, params structs.PaginationParams) ([]models.DbPost, error) {
+	if s.cfg.Disabled || params.Reverse || params.Limit > latestSize {
+		return s.db.GetNextPosts(ctx, userId, params)
+	}
+
+	var latest []models.DbPost
+	err := s.readThrough(ctx, "latest_posts", latestKey(userId), &latest, func(ctx context.Context) (any, error) {
+		return s.db.GetNextPosts(ctx, userId, structs.PaginationParams{Limit: latestSize, Cursor: latestCursor})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if page, ok := firstPage(latest, params); ok {
+		return page, nil
+	}
+	return s.db.GetNextPosts(ctx, userId, params)
+}
+
+func (s *Storage) SavePost(ctx context.Context, post *models.Post) error {
+	if err := s.db.SavePost(ctx, post); err != nil {
+		return err
+	}
+	s.invalidate(ctx, latestKey(post.UserId))
+	return nil
+}
+
+func (s *Storage) DeletePost(ctx context.Context, postId uuid.UUID) error {
+	// Автора берём из базы: у закэшированного поста он мог устареть после анонимизации
+	post, getErr := s.db.GetPost(ctx, postId)
+	if err := s.db.DeletePost(ctx, postId); err != nil {
+		return err
+	}
+	s.invalidatePost(ctx, postId, post, getErr)
+	return nil
+}
+
+func (s *Storage) SetPostHidden(ctx context.Context, postId uuid.UUID, hidden bool) error {
+	post, getErr := s.db.GetPost(ctx, postId)
+	if err := s.db.SetPostHidden(ctx, postId, hidden); err != nil {
+		return err
+	}
+	s.invalidatePost(ctx, postId, post, getErr)
+	return nil
+}
+
+// readThrough заполняет dest из кэша или через load. Одновременные промахи по одному ключу
+// выполняют load один раз, остальные запросы ждут его результата
+func (s *Storage) readThrough(ctx context.Context, name string, key string, dest any, load func(ctx context.Context) (any, error)) error {
+	const op = "repository.cache.readThrough"
+
+	if s.local != nil {
+		if data, ok := s.local.Get(key); ok {
+			metrics.CacheRequests.WithLabelValues(name, "local_hit").Inc()
+			return unmarshal(op, data, dest)
+		}
+	}
+
+	value, err, _ := s.group.Do(key, func() (any, error) {
+		// Результат нужен всем ожидающим, поэтому отмена первого запроса не должна его прерывать
+		ctx := context.WithoutCancel(ctx)
+
+		data, err := s.rdb.Get(ctx, key).Bytes()
+		if err == nil {
+			metrics.CacheRequests.WithLabelValues(name, "redis_hit").Inc()
+			s.addLocal(key, data)
+			return data, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			sl.FromContext(ctx, s.log).Warn("failed to read cache", slog.String("key", key), sl.Error(err))
+		}
+
+		metrics.CacheRequests.WithLabelValues(name, "miss").Inc()
+		// Поколение читается до загрузки: запись в базу после этого момента его увеличит
+		generation, err := s.rdb.Get(ctx, generationKey(key)).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			sl.FromContext(ctx, s.log).Warn("failed to read cache generation", slog.String("key", key), sl.Error(err))
+		}
+		cacheable := err == nil || errors.Is(err, redis.Nil)
+
+		loaded, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		data, err = json.Marshal(loaded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if !cacheable {
+			return data, nil
+		}
+
+		stored, err := setIfCurrent.Run(ctx, s.rdb, []string{key, generationKey(key)}, generation, data, s.cfg.TTL.Milliseconds()).Int()
+		if err != nil {
+			sl.FromContext(ctx, s.log).Warn("failed to write cache", slog.String("key", key), sl.Error(err))
+			return data, nil
+		}
+		if stored == 1 {
+			s.addLocal(key, data)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	return unmarshal(op, value.([]byte), dest)
+}
+
+func (s *Storage) invalidatePost(ctx context.Context, postId uuid.UUID, post models.DbPost, getErr error) {
+	keys := []string{postKey(postId)}
+	if getErr == nil {
+		keys = append(keys, latestKey(post.UserId))
+	}
+	s.invalidate(ctx, keys...)
+}
+
+// invalidate удаляет ключи после записи в базу и увеличивает их поколение, чтобы загрузки,
+// начатые до записи, не вернули старое значение в Redis. Если Redis недоступен, старые значения живут до конца TTL
+func (s *Storage) invalidate(ctx context.Context, keys ...string) {
+	if s.cfg.Disabled {
+		return
+	}
+
+	for _, key := range keys {
+		s.group.Forget(key)
+		if s.local != nil {
+			s.local.Remove(key)
+		}
+	}
+
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, keys...)
+		for _, key := range keys {
+			// Поколение переживает значение, чтобы долгая загрузка, начатая до записи, не застала его сброшенным
+			pipe.Incr(ctx, generationKey(key))
+			pipe.Expire(ctx, generationKey(key), 2*s.cfg.TTL)
+		}
+		return nil
+	})
+	if err != nil {
+		sl.FromContext(ctx, s.log).Error("failed to invalidate cache", slog.Any("keys", keys), sl.Error(err))
+	}
+}
+
+func (s *Storage) addLocal(key string, data []byte) {
+	if s.local != nil {
+		s.local.Add(key, data)
+	}
+}
+
+// firstPage вырезает страницу из последних постов. Страница верна, если она заполнена
+// или в кэше лежат вообще все посты пользователя
+func firstPage(latest []models.DbPost, params structs.PaginationParams) ([]models.DbPost, bool) {
+	start := len(latest)
+	for i, post := range latest {
+		if post.CreatedAt.Before(params.Cursor) {
+			start = i
+			break
+		}
+	}
+
+	page := latest[start:]
+	if len(page) >= params.Limit {
+		return page[:params.Limit], true
+	}
+	return page, len(latest) < latestSize
+}
+
+func unmarshal(op string, data []byte, dest any) error {
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func postKey(postId uuid.UUID) string {
+	return fmt.Sprintf("%s:post:%s", keyPrefix, postId)
+}
+
+func latestKey(userId uuid.UUID) string {
+	return fmt.Sprintf("%s:latest_posts:%s", keyPrefix, userId)
+}
+
+func generationKey(key string) string {
+	return key + ":generation"
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"new_service/internal/config"
+	"new_service/internal/handlers/structs"
+	"new_service/internal/models"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// posts возвращает n постов от новых к старым, как их отдаёт GetNextPosts
+func posts(n int) []models.DbPost {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := make([]models.DbPost, n)
+	for i := range result {
+		result[i] = models.DbPost{PostId: uuid.New(), CreatedAt: base.Add(time.Duration(n-i) * time.Hour)}
+	}
+	return result
+}
+
+func TestFirstPage(t *testing.T) {
+	full := posts(latestSize)
+	short := posts(2)
+
+	tests := []struct {
+		name   string
+		latest []models.DbPost
+		params structs.PaginationParams
+		want   []models.DbPost
+		ok     bool
+	}{
+		{"newest posts", full, structs.PaginationParams{Limit: 3, Cursor: latestCursor}, full[:3], true},
+		{"cursor inside the cache", full, structs.PaginationParams{Limit: 2, Cursor: full[1].CreatedAt}, full[2:4], true},
+		{"page reaches the end of a full cache", full, structs.PaginationParams{Limit: 3, Cursor: full[3].CreatedAt}, nil, false},
+		{"cursor equal to a post excludes it", full, structs.PaginationParams{Limit: 4, Cursor: full[0].CreatedAt}, full[1:5], true},
+		{"user has fewer posts than the cache holds", short, structs.PaginationParams{Limit: 5, Cursor: latestCursor}, short, true},
+		{"cursor before all posts of a short cache", short, structs.PaginationParams{Limit: 5, Cursor: short[1].CreatedAt}, []models.DbPost{}, true},
+		{"no posts", []models.DbPost{}, structs.PaginationParams{Limit: 5, Cursor: latestCursor}, []models.DbPost{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := firstPage(tt.latest, tt.params)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && !slices.EqualFunc(got, tt.want, func(a, b models.DbPost) bool { return a.PostId == b.PostId }) {
+				t.Errorf("got %d posts, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func newTestCache(t *testing.T, localSize int) *Storage {
+	t.Helper()
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(log, nil, rdb, config.Cache{TTL: time.Minute, LocalSize: localSize, LocalTTL: time.Minute})
+}
+
+// counter отдаёт значение, которое меняется при каждой загрузке, и считает загрузки
+type counter struct {
+	loads int
+}
+
+func (c *counter) load(ctx context.Context) (any, error) {
+	c.loads++
+	return c.loads, nil
+}
+
+func read(t *testing.T, s *Storage, key string, load func(ctx context.Context) (any, error)) int {
+	t.Helper()
+	var value int
+	if err := s.readThrough(context.Background(), "test", key, &value, load); err != nil {
+		t.Fatalf("readThrough: %v", err)
+	}
+	return value
+}
+
+func TestInvalidate(t *testing.T) {
+	for _, localSize := range []int{0, 10} {
+		s := newTestCache(t, localSize)
+		var c counter
+
+		if got := read(t, s, "cache:test", c.load); got != 1 {
+			t.Fatalf("local size %d: first read = %d, want 1", localSize, got)
+		}
+		if got := read(t, s, "cache:test", c.load); got != 1 {
+			t.Errorf("local size %d: cached read = %d, want 1", localSize, got)
+		}
+
+		s.invalidate(context.Background(), "cache:test")
+		if got := read(t, s, "cache:test", c.load); got != 2 {
+			t.Errorf("local size %d: read after invalidate = %d, want 2", localSize, got)
+		}
+		if c.loads != 2 {
+			t.Errorf("local size %d: %d loads, want 2", localSize, c.loads)
+		}
+	}
+}
+
+func TestInvalidateDuringLoad(t *testing.T) {
+	for _, localSize := range []int{0, 10} {
+		s := newTestCache(t, localSize)
+		var c counter
+
+		// Загрузка прочитала базу до записи, а инвалидация прошла раньше, чем загрузка закончилась
+		stale := func(ctx context.Context) (any, error) {
+			value, err := c.load(ctx)
+			s.invalidate(ctx, "cache:test")
+			return value, err
+		}
+		if got := read(t, s, "cache:test", stale); got != 1 {
+			t.Fatalf("local size %d: first read = %d, want 1", localSize, got)
+		}
+
+		if got := read(t, s, "cache:test", c.load); got != 2 {
+			t.Errorf("local size %d: stale value was cached, got %d, want 2", localSize, got)
+		}
+		if got := read(t, s, "cache:test", c.load); got != 2 {
+			t.Errorf("local size %d: fresh value was not cached, got %d, want 2", localSize, got)
+		}
+	}
+}
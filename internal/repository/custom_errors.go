@@ -1,8 +1,16 @@
 package custom_errors
 
-import "errors"
+import "new_service/internal/lib/apperr"
 
 var (
-	ErrUserDoesNotExist = errors.New("user does not exist")
-	ErrInvalidPassword  = errors.New("invalid password")
+	ErrUserDoesNotExist   = apperr.NotFound("user does not exist")
+	ErrUserExists         = apperr.Conflict("user with this email or username already exists")
+	ErrInvalidPassword    = apperr.Unauthorized("invalid password")
+	ErrPostDoesNotExist   = apperr.NotFound("post does not exist")
+	ErrEmailNotVerified   = apperr.Forbidden("email is not verified by provider")
+	ErrNoDeletionToCancel = apperr.NotFound("account deletion is not scheduled")
+	ErrUsernameTaken      = apperr.Conflict("username is already taken")
+	ErrQuotaExceeded      = apperr.Forbidden("media quota exceeded")
+	ErrMediaDoesNotExist  = apperr.NotFound("media does not exist")
+	ErrAccountUnavailable = apperr.Forbidden("account is scheduled for deletion")
 )
@@ -0,0 +1,188 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"new_service/migrations"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+)
+
+// lockKey — ключ advisory lock, под которым реплики по очереди применяют миграции
+const lockKey int64 = 0x626c6f6767657279
+
+type Migrator struct {
+	m           *migrate.Migrate
+	connString  string
+	lockTimeout time.Duration
+}
+
+type Migration struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+type Status struct {
+	Version    uint
+	Dirty      bool
+	Migrations []Migration
+}
+
+func New(connString string, lockTimeout time.Duration) (*Migrator, error) {
+	const op = "repository.migrator.New"
+
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	databaseURL, err := driverURL(connString)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", source, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Migrator{m: m, connString: connString, lockTimeout: lockTimeout}, nil
+}
+
+func (m *Migrator) Close() error {
+	sourceErr, dbErr := m.m.Close()
+	return errors.Join(sourceErr, dbErr)
+}
+
+// Up применяет все новые миграции
+func (m *Migrator) Up(ctx context.Context) error {
+	const op = "repository.migrator.Up"
+
+	err := m.withLock(ctx, func() error {
+		if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Down откатывает последние steps миграций
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	const op = "repository.migrator.Down"
+
+	if steps <= 0 {
+		return fmt.Errorf("%s: steps must be positive", op)
+	}
+
+	err := m.withLock(ctx, func() error {
+		if err := m.m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Force выставляет версию без выполнения миграций и снимает флаг dirty
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	const op = "repository.migrator.Force"
+
+	err := m.withLock(ctx, func() error {
+		return m.m.Force(version)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (m *Migrator) Status() (Status, error) {
+	const op = "repository.migrator.Status"
+
+	var status Status
+	version, dirty, err := m.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return status, fmt.Errorf("%s: %w", op, err)
+	}
+	status.Version = version
+	status.Dirty = dirty
+
+	available, err := available()
+	if err != nil {
+		return status, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, migration := range available {
+		migration.Applied = migration.Version <= version
+		status.Migrations = append(status.Migrations, migration)
+	}
+
+	return status, nil
+}
+
+// withLock держит advisory lock на отдельном соединении, пока выполняется fn
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	conn, err := pgx.Connect(ctx, m.connString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	lockCtx, cancel := context.WithTimeout(ctx, m.lockTimeout)
+	defer cancel()
+
+	if _, err := conn.Exec(lockCtx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	return fn()
+}
+
+// available перечисляет встроенные миграции по up-файлам
+func available() ([]Migration, error) {
+	entries, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Migration
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry, ".up.sql")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration name %s: %w", entry, err)
+		}
+		list = append(list, Migration{Version: uint(version), Name: name})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+
+	return list, nil
+}
+
+// driverURL переводит строку подключения на схему драйвера pgx5
+func driverURL(connString string) (string, error) {
+	for _, scheme := range []string{"postgres://", "postgresql://"} {
+		if strings.HasPrefix(connString, scheme) {
+			return "pgx5://" + strings.TrimPrefix(connString, scheme), nil
+		}
+	}
+	return "", errors.New("postgres_conn_string must be a postgres:// url")
+}
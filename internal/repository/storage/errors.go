@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"new_service/internal/lib/apperr"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Коды ошибок Postgres, которые имеют смысл для клиента
+const (
+	pgUniqueViolation      = "23505"
+	pgForeignKeyViolation  = "23503"
+	pgCheckViolation       = "23514"
+	pgNotNullViolation     = "23502"
+	pgStringTooLong        = "22001"
+	pgInvalidTextValue     = "22P02"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+// translate переводит ошибки pgx в доменные; исходная ошибка остаётся в цепочке для логов
+func translate(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return apperr.Wrap(apperr.KindNotFound, "resource does not exist", err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return apperr.Unavailable("database query timed out", err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return apperr.Wrap(apperr.KindConflict, "resource already exists", err)
+	case pgForeignKeyViolation:
+		return apperr.Wrap(apperr.KindNotFound, "related resource does not exist", err)
+	case pgCheckViolation, pgNotNullViolation, pgStringTooLong, pgInvalidTextValue:
+		return apperr.Wrap(apperr.KindInvalid, "invalid value", err)
+	case pgSerializationFailure, pgDeadlockDetected:
+		return apperr.Wrap(apperr.KindConflict, "concurrent update, try again", err)
+	}
+	return err
+}
@@ -4,63 +4,106 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"new_service/internal/config"
 	"new_service/internal/handlers/structs"
+	"new_service/internal/lib/password"
 	"new_service/internal/models"
 	custom_errors "new_service/internal/repository"
+	"time"
 
+	"github.com/exaring/otelpgx"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// DeletedUserId владеет постами анонимизированных аккаунтов
+var DeletedUserId = uuid.Nil
+
 type Storage struct {
-	Conn *pgxpool.Pool
+	Conn         *pgxpool.Pool
+	hasher       *password.Hasher
+	queryTimeout time.Duration
 }
 
-func New(connectString string) (*Storage, error) {
+func New(connectString string, pool config.PostgresPool, hasher *password.Hasher) (*Storage, error) {
 	const op = "repository.storage.New"
 
-	conn, err := pgxpool.New(context.Background(), connectString)
+	poolConfig, err := pgxpool.ParseConfig(connectString)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	poolConfig.MaxConns = pool.MaxConns
+	poolConfig.MinConns = pool.MinConns
+	poolConfig.MaxConnLifetime = pool.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = pool.MaxConnIdleTime
+	// Спаны запросов создаются через глобальный TracerProvider, поэтому трассировку нужно настроить раньше
+	poolConfig.ConnConfig.Tracer = otelpgx.NewTracer(otelpgx.WithTrimSQLInSpanName())
+
+	conn, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{Conn: conn}, nil
+	return &Storage{Conn: conn, hasher: hasher, queryTimeout: pool.QueryTimeout}, nil
 
 }
 
-func (s *Storage) SaveUser(email string, password string, username string) error {
+// withTimeout ограничивает запрос таймаутом из конфига; отключение клиента отменяет его раньше
+func (s *Storage) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+func (s *Storage) SaveUser(ctx context.Context, email string, userPassword string, username string) error {
 	const op = "repository.storage.SaveUser"
 
-	hash_password, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash_password, err := s.hasher.Hash(userPassword)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, translate(err))
 	}
 
+	// Таймаут считается после хэширования, чтобы медленный хэш не съедал время запроса
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	if username != "" {
-		_, err = s.Conn.Exec(context.Background(),
-			`INSERT INTO users(email, username, password_hash) VALUES($1, $2, $3)`,
+		// Старое имя другого пользователя нельзя занять, пока действует редирект
+		var tag pgconn.CommandTag
+		tag, err = s.Conn.Exec(ctx,
+			`INSERT INTO users(email, username, password_hash)
+			SELECT $1, $2, $3
+			WHERE NOT EXISTS (SELECT 1 FROM username_redirects WHERE old_username = $2 AND expires_at > NOW())`,
 			email, username, hash_password,
 		)
+		if err == nil && tag.RowsAffected() == 0 {
+			return custom_errors.ErrUsernameTaken
+		}
 	} else {
-		_, err = s.Conn.Exec(context.Background(),
+		_, err = s.Conn.Exec(ctx,
 			`INSERT INTO users(email, password_hash) VALUES($1, $2)`,
 			email, hash_password,
 		)
 	}
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return custom_errors.ErrUserExists
+		}
+		return fmt.Errorf("%s: %w", op, translate(err))
 	}
 
 	return nil
 }
 
-func (s *Storage) GetUserPasswordByEmail(email string) (string, uuid.UUID, error) {
+func (s *Storage) GetUserPasswordByEmail(ctx context.Context, email string) (string, uuid.UUID, error) {
 	const op = "repository.storage.GetUser"
 
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	row := s.Conn.QueryRow(
-		context.Background(),
+		ctx,
 		`SELECT password_hash, user_id FROM users WHERE email = $1`,
 		email,
 	)
@@ -69,16 +112,22 @@ func (s *Storage) GetUserPasswordByEmail(email string) (string, uuid.UUID, error
 
 	err := row.Scan(&password_hash, &user_id)
 	if err != nil {
-		return "", user_id, fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", user_id, custom_errors.ErrUserDoesNotExist
+		}
+		return "", user_id, fmt.Errorf("%s: %w", op, translate(err))
 	}
 	return password_hash, user_id, nil
 }
 
-func (s *Storage) GetUserPasswordByUsername(username string) (string, uuid.UUID, error) {
+func (s *Storage) GetUserPasswordByUsername(ctx context.Context, username string) (string, uuid.UUID, error) {
 	const op = "repository.storage.GetUser"
 
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	row := s.Conn.QueryRow(
-		context.Background(),
+		ctx,
 		`SELECT password_hash, user_id FROM users WHERE username = $1`,
 		username,
 	)
@@ -90,93 +139,781 @@ func (s *Storage) GetUserPasswordByUsername(username string) (string, uuid.UUID,
 		if errors.Is(err, pgx.ErrNoRows) {
 			return "", user_id, custom_errors.ErrUserDoesNotExist
 		}
-		return "", user_id, fmt.Errorf("%s: %w", op, err)
+		return "", user_id, fmt.Errorf("%s: %w", op, translate(err))
 	}
 	return password_hash, user_id, nil
 }
 
-func (s *Storage) SavePost(post *models.Post) error {
+func (s *Storage) GetUserPasswordById(ctx context.Context, userId uuid.UUID) (string, error) {
+	const op = "repository.storage.GetUserPasswordById"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var password_hash string
+	err := s.Conn.QueryRow(
+		ctx,
+		`SELECT password_hash FROM users WHERE user_id = $1`,
+		userId,
+	).Scan(&password_hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", custom_errors.ErrUserDoesNotExist
+		}
+		return "", fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return password_hash, nil
+}
+
+func (s *Storage) UpdateUserPassword(ctx context.Context, userId uuid.UUID, userPassword string) error {
+	const op = "repository.storage.UpdateUserPassword"
+
+	hash_password, err := s.hasher.Hash(userPassword)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.Conn.Exec(
+		ctx,
+		`UPDATE users SET password_hash = $2, updated_at = NOW() WHERE user_id = $1`,
+		userId, hash_password,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return custom_errors.ErrUserDoesNotExist
+	}
+	return nil
+}
+
+func (s *Storage) GetUserRole(ctx context.Context, userId uuid.UUID) (string, error) {
+	const op = "repository.storage.GetUserRole"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var role string
+	err := s.Conn.QueryRow(
+		ctx,
+		`SELECT role FROM users WHERE user_id = $1`,
+		userId,
+	).Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", custom_errors.ErrUserDoesNotExist
+		}
+		return "", fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return role, nil
+}
+
+func (s *Storage) SetUserRole(ctx context.Context, userId uuid.UUID, role string) error {
+	const op = "repository.storage.SetUserRole"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.Conn.Exec(
+		ctx,
+		`UPDATE users SET role = $2, updated_at = NOW() WHERE user_id = $1`,
+		userId, role,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return custom_errors.ErrUserDoesNotExist
+	}
+	return nil
+}
+
+// LinkExternalIdentity находит пользователя по внешней учётной записи,
+// привязывает её к аккаунту с тем же подтверждённым email или создаёт новый аккаунт
+func (s *Storage) LinkExternalIdentity(ctx context.Context, issuer string, subject string, email string, emailVerified bool) (uuid.UUID, error) {
+	const op = "repository.storage.LinkExternalIdentity"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.Conn.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	defer tx.Rollback(ctx)
+
+	var user_id uuid.UUID
+	err = tx.QueryRow(ctx,
+		`SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2`,
+		issuer, subject,
+	).Scan(&user_id)
+	if err == nil {
+		return user_id, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	if email == "" || !emailVerified {
+		return uuid.Nil, custom_errors.ErrEmailNotVerified
+	}
+
+	// Служебный пользователь и аккаунты в ожидании удаления не привязываются: вход через провайдера
+	// отдал бы чужие анонимизированные посты или оживил аккаунт в обход восстановления
+	var pendingDeletion bool
+	err = tx.QueryRow(ctx,
+		`SELECT user_id, deletion_scheduled_at IS NOT NULL FROM users WHERE email = $1`,
+		email,
+	).Scan(&user_id, &pendingDeletion)
+	if err == nil && (user_id == DeletedUserId || pendingDeletion) {
+		return uuid.Nil, custom_errors.ErrAccountUnavailable
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Пустой хэш не проходит проверку, вход по паролю для такого аккаунта невозможен
+		err = tx.QueryRow(ctx,
+			`INSERT INTO users(email, password_hash) VALUES($1, '') RETURNING user_id`,
+			email,
+		).Scan(&user_id)
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO user_identities(issuer, subject, user_id, email) VALUES($1, $2, $3, $4)`,
+		issuer, subject, user_id, email,
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return user_id, nil
+}
+
+func (s *Storage) GetUser(ctx context.Context, userId uuid.UUID) (models.User, error) {
+	const op = "repository.storage.GetUser"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var user models.User
+	err := s.Conn.QueryRow(
+		ctx,
+		`SELECT user_id, email, username, display_name, bio, website, avatar_url,
+			role, deletion_scheduled_at, updated_at, created_at
+		FROM users WHERE user_id = $1`,
+		userId,
+	).Scan(
+		&user.UserId, &user.Email, &user.Username, &user.DisplayName, &user.Bio, &user.Website, &user.AvatarURL,
+		&user.Role, &user.DeletionScheduledAt, &user.UpdatedAt, &user.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, custom_errors.ErrUserDoesNotExist
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return user, nil
+}
+
+func (s *Storage) GetPublicProfile(ctx context.Context, username string) (models.PublicProfile, error) {
+	const op = "repository.storage.GetPublicProfile"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var profile models.PublicProfile
+	err := s.Conn.QueryRow(
+		ctx,
+		`SELECT u.username, u.display_name, u.bio, u.website, u.avatar_url, u.created_at,
+			(SELECT COUNT(*) FROM posts p WHERE p.user_id = u.user_id AND p.hidden = FALSE),
+			(SELECT COUNT(*) FROM follows f WHERE f.followee_id = u.user_id),
+			(SELECT COUNT(*) FROM follows f WHERE f.follower_id = u.user_id)
+		FROM users u
+		WHERE u.username = $1 AND u.deletion_scheduled_at IS NULL`,
+		username,
+	).Scan(
+		&profile.Username, &profile.DisplayName, &profile.Bio, &profile.Website, &profile.AvatarURL, &profile.CreatedAt,
+		&profile.PostsCount, &profile.FollowersCount, &profile.FollowingCount,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.PublicProfile{}, custom_errors.ErrUserDoesNotExist
+		}
+		return models.PublicProfile{}, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return profile, nil
+}
+
+// GetUsernameRedirect возвращает текущий username пользователя, который недавно сменил имя
+func (s *Storage) GetUsernameRedirect(ctx context.Context, oldUsername string) (string, error) {
+	const op = "repository.storage.GetUsernameRedirect"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var username string
+	err := s.Conn.QueryRow(
+		ctx,
+		`SELECT u.username FROM username_redirects r
+		JOIN users u ON u.user_id = r.user_id
+		WHERE r.old_username = $1 AND r.expires_at > NOW() AND u.username IS NOT NULL`,
+		oldUsername,
+	).Scan(&username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", custom_errors.ErrUserDoesNotExist
+		}
+		return "", fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return username, nil
+}
+
+func (s *Storage) UpdateProfile(ctx context.Context, userId uuid.UUID, update models.ProfileUpdate, redirectTTL time.Duration) error {
+	const op = "repository.storage.UpdateProfile"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	defer tx.Rollback(ctx)
+
+	var oldUsername *string
+	err = tx.QueryRow(ctx, `SELECT username FROM users WHERE user_id = $1 FOR UPDATE`, userId).Scan(&oldUsername)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return custom_errors.ErrUserDoesNotExist
+		}
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	usernameChanged := update.Username != nil && (oldUsername == nil || *oldUsername != *update.Username)
+	if usernameChanged {
+		// Старое имя другого пользователя нельзя занять, пока действует редирект
+		var redirectOwner uuid.UUID
+		err = tx.QueryRow(ctx,
+			`SELECT user_id FROM username_redirects WHERE old_username = $1 AND expires_at > NOW()`,
+			*update.Username,
+		).Scan(&redirectOwner)
+		if err == nil && redirectOwner != userId {
+			return custom_errors.ErrUsernameTaken
+		}
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, translate(err))
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM username_redirects WHERE old_username = $1`, *update.Username)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, translate(err))
+		}
+	}
+
+	columns := []struct {
+		name  string
+		value *string
+	}{
+		{"username", update.Username},
+		{"display_name", update.DisplayName},
+		{"bio", update.Bio},
+		{"website", update.Website},
+		{"avatar_url", update.AvatarURL},
+	}
+
+	query := `UPDATE users SET updated_at = NOW()`
+	args := []any{userId}
+	for _, column := range columns {
+		if column.value == nil {
+			continue
+		}
+		var value any
+		if *column.value != "" {
+			value = *column.value
+		}
+		args = append(args, value)
+		query += fmt.Sprintf(", %s = $%d", column.name, len(args))
+	}
+	query += ` WHERE user_id = $1`
+
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return custom_errors.ErrUsernameTaken
+		}
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	if usernameChanged && oldUsername != nil {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO username_redirects(old_username, user_id, expires_at)
+			VALUES($1, $2, NOW() + make_interval(secs => $3))
+			ON CONFLICT (old_username) DO UPDATE SET user_id = EXCLUDED.user_id, expires_at = EXCLUDED.expires_at`,
+			*oldUsername, userId, redirectTTL.Seconds(),
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, translate(err))
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return nil
+}
+
+func (s *Storage) GetUserIdentities(ctx context.Context, userId uuid.UUID) ([]models.ExternalIdentity, error) {
+	const op = "repository.storage.GetUserIdentities"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.Conn.Query(
+		ctx,
+		`SELECT issuer, subject, email, created_at FROM user_identities WHERE user_id = $1`,
+		userId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	identities, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.ExternalIdentity])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return identities, nil
+}
+
+func (s *Storage) GetAllUserPosts(ctx context.Context, userId uuid.UUID) ([]models.DbPost, error) {
+	const op = "repository.storage.GetAllUserPosts"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.Conn.Query(
+		ctx,
+		`SELECT * FROM posts WHERE user_id = $1 ORDER BY created_at DESC`,
+		userId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	posts, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.DbPost])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return posts, nil
+}
+
+func (s *Storage) ScheduleUserDeletion(ctx context.Context, userId uuid.UUID, mode string, gracePeriod time.Duration) (time.Time, error) {
+	const op = "repository.storage.ScheduleUserDeletion"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var deleteAt time.Time
+	err := s.Conn.QueryRow(
+		ctx,
+		`UPDATE users SET deletion_scheduled_at = NOW() + make_interval(secs => $2), deletion_mode = $3, updated_at = NOW()
+		WHERE user_id = $1
+		RETURNING deletion_scheduled_at`,
+		userId, gracePeriod.Seconds(), mode,
+	).Scan(&deleteAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, custom_errors.ErrUserDoesNotExist
+		}
+		return time.Time{}, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return deleteAt, nil
+}
+
+func (s *Storage) CancelUserDeletion(ctx context.Context, userId uuid.UUID) error {
+	const op = "repository.storage.CancelUserDeletion"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.Conn.Exec(
+		ctx,
+		`UPDATE users SET deletion_scheduled_at = NULL, deletion_mode = NULL, updated_at = NOW()
+		WHERE user_id = $1 AND deletion_scheduled_at IS NOT NULL`,
+		userId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return custom_errors.ErrNoDeletionToCancel
+	}
+	return nil
+}
+
+// BlobDeleter удаляет файлы медиа, ключи хранятся в media.storage_key
+type BlobDeleter interface {
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// PurgeDeletedUsers удаляет аккаунты с истёкшим сроком ожидания вместе с их файлами.
+// Посты анонимизируемых аккаунтов переходят к служебному пользователю, остальные удаляются каскадно.
+// Файлы удаляются до коммита: если хранилище недоступно, аккаунты остаются до следующего запуска.
+// За вызов обрабатывается не больше limit аккаунтов; query_timeout не применяется, таймаут задаёт вызывающий
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, blobs BlobDeleter, limit int) (int64, error) {
+	const op = "repository.storage.PurgeDeletedUsers"
+
+	tx, err := s.Conn.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка строк не даёт отменить удаление или загрузить новый файл, пока идёт очистка
+	rows, err := tx.Query(ctx,
+		`SELECT user_id FROM users
+		WHERE deletion_scheduled_at <= NOW() AND user_id <> $1
+		ORDER BY deletion_scheduled_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`,
+		DeletedUserId, limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	userIds, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	if len(userIds) == 0 {
+		return 0, nil
+	}
+
+	rows, err = tx.Query(ctx,
+		`SELECT storage_key FROM media WHERE user_id = ANY($1)`,
+		userIds,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	for _, key := range keys {
+		if err := blobs.DeletePrefix(ctx, key); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE posts SET user_id = $1 WHERE user_id IN (
+			SELECT user_id FROM users
+			WHERE user_id = ANY($2) AND deletion_mode = 'anonymize'
+		)`,
+		DeletedUserId, userIds,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	tag, err := tx.Exec(ctx,
+		`DELETE FROM users WHERE user_id = ANY($1)`,
+		userIds,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return tag.RowsAffected(), nil
+}
+
+// CreateMedia резервирует место под файл, проверяя квоту пользователя.
+// Строка пользователя блокируется до конца транзакции, поэтому параллельные загрузки проверяют квоту по очереди
+func (s *Storage) CreateMedia(ctx context.Context, media *models.Media, quota int64) error {
+	const op = "repository.storage.CreateMedia"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.Conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `SELECT 1 FROM users WHERE user_id = $1 FOR UPDATE`, media.UserId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	var used int64
+	err = tx.QueryRow(ctx, `SELECT COALESCE(SUM(size), 0) FROM media WHERE user_id = $1`, media.UserId).Scan(&used)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	if used+media.Size > quota {
+		return custom_errors.ErrQuotaExceeded
+	}
+
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO media(media_id, user_id, storage_key, content_type, size, url, status)
+		VALUES($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at`,
+		media.MediaId, media.UserId, media.StorageKey, media.ContentType, media.Size, media.URL, media.Status,
+	).Scan(&media.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return nil
+}
+
+func (s *Storage) DeleteMedia(ctx context.Context, mediaId uuid.UUID) error {
+	const op = "repository.storage.DeleteMedia"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.Conn.Exec(
+		ctx,
+		`DELETE FROM media WHERE media_id = $1`,
+		mediaId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return nil
+}
+
+func (s *Storage) GetMedia(ctx context.Context, mediaId uuid.UUID) (models.Media, error) {
+	const op = "repository.storage.GetMedia"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var media models.Media
+	err := s.Conn.QueryRow(
+		ctx,
+		`SELECT media_id, user_id, storage_key, content_type, size, url, status,
+			width, height, variants, error, processed_at, created_at
+		FROM media WHERE media_id = $1`,
+		mediaId,
+	).Scan(
+		&media.MediaId, &media.UserId, &media.StorageKey, &media.ContentType, &media.Size, &media.URL, &media.Status,
+		&media.Width, &media.Height, &media.Variants, &media.Error, &media.ProcessedAt, &media.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Media{}, custom_errors.ErrMediaDoesNotExist
+		}
+		return models.Media{}, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return media, nil
+}
+
+func (s *Storage) SetMediaProcessing(ctx context.Context, mediaId uuid.UUID) error {
+	const op = "repository.storage.SetMediaProcessing"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.Conn.Exec(
+		ctx,
+		`UPDATE media SET status = 'processing' WHERE media_id = $1`,
+		mediaId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return nil
+}
+
+func (s *Storage) CompleteMedia(ctx context.Context, mediaId uuid.UUID, width int, height int, url string, size int64, variants []models.MediaVariant) error {
+	const op = "repository.storage.CompleteMedia"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.Conn.Exec(
+		ctx,
+		`UPDATE media SET status = 'ready', width = $2, height = $3, url = $4, size = $5,
+			variants = $6, error = NULL, processed_at = NOW()
+		WHERE media_id = $1`,
+		mediaId, width, height, url, size, variants,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return nil
+}
+
+func (s *Storage) FailMedia(ctx context.Context, mediaId uuid.UUID, reason string) error {
+	const op = "repository.storage.FailMedia"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.Conn.Exec(
+		ctx,
+		`UPDATE media SET status = 'failed', error = $2, processed_at = NOW() WHERE media_id = $1`,
+		mediaId, reason,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return nil
+}
+
+// FailStaleMedia помечает неудачными загрузки, обработка которых оборвалась при перезапуске
+func (s *Storage) FailStaleMedia(ctx context.Context, olderThan time.Duration) (int64, error) {
+	const op = "repository.storage.FailStaleMedia"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.Conn.Exec(
+		ctx,
+		`UPDATE media SET status = 'failed', error = 'processing was interrupted', processed_at = NOW()
+		WHERE status IN ('pending', 'processing') AND created_at < NOW() - make_interval(secs => $1)`,
+		olderThan.Seconds(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (s *Storage) SavePost(ctx context.Context, post *models.Post) error {
 	const op = "repository.storage.SavePost"
 
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	_, err := s.Conn.Exec(
-		context.Background(),
+		ctx,
 		`INSERT INTO posts(user_id, title, content) VALUES($1, $2, $3)`,
 		post.UserId, post.Title, post.Content,
 	)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, translate(err))
 	}
 
 	return nil
 }
 
-func (s *Storage) GetNextPosts(userId uuid.UUID, paginationParams structs.PaginationParams) ([]models.DbPost, error) {
+func (s *Storage) GetNextPosts(ctx context.Context, userId uuid.UUID, paginationParams structs.PaginationParams) ([]models.DbPost, error) {
 	const op = "repository.storage.GetNextPosts"
 
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	var query string
 	if paginationParams.Reverse {
 		query = `SELECT * FROM (
 				SELECT * FROM posts
-				WHERE user_id = $1 AND created_at > $2
+				WHERE user_id = $1 AND created_at > $2 AND hidden = FALSE
 				ORDER BY created_at ASC
 				LIMIT $3
 				) AS subquery
 				ORDER BY created_at DESC;`
 	} else {
 		query = `SELECT * FROM posts
-				WHERE user_id = $1 AND created_at < $2
+				WHERE user_id = $1 AND created_at < $2 AND hidden = FALSE
 				ORDER BY created_at DESC
 				LIMIT $3;`
 	}
 
 	posts, err := s.Conn.Query(
-		context.Background(),
+		ctx,
 		query,
 		userId, paginationParams.Cursor, paginationParams.Limit,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, translate(err))
 	}
 	defer posts.Close()
 
 	parsedPosts, err := pgx.CollectRows(posts, pgx.RowToStructByName[models.DbPost])
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, translate(err))
 	}
 
 	return parsedPosts, nil
 }
 
-func (s *Storage) GetPost(postId uuid.UUID) (models.DbPost, error) {
+func (s *Storage) GetPost(ctx context.Context, postId uuid.UUID) (models.DbPost, error) {
 	const op = "repository.storage.GetPost"
 
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	post, err := s.Conn.Query(
-		context.Background(),
+		ctx,
 		`SELECT * FROM posts WHERE post_id = $1`,
 		postId,
 	)
 	if err != nil {
-		return models.DbPost{}, fmt.Errorf("%s: %w", op, err)
+		return models.DbPost{}, fmt.Errorf("%s: %w", op, translate(err))
 	}
 
 	parsed_post, err := pgx.CollectOneRow(post, pgx.RowToStructByName[models.DbPost])
 	if err != nil {
-		return models.DbPost{}, fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.DbPost{}, custom_errors.ErrPostDoesNotExist
+		}
+		return models.DbPost{}, fmt.Errorf("%s: %w", op, translate(err))
 	}
 
 	return parsed_post, nil
 }
 
-func (s *Storage) DeletePost(postId uuid.UUID) error {
+func (s *Storage) DeletePost(ctx context.Context, postId uuid.UUID) error {
 	const op = "repository.storage.DeletePost"
 
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	_, err := s.Conn.Exec(
-		context.Background(),
+		ctx,
 		`DELETE FROM posts WHERE post_id = $1`,
 		postId,
 	)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	return nil
+}
+
+func (s *Storage) SetPostHidden(ctx context.Context, postId uuid.UUID, hidden bool) error {
+	const op = "repository.storage.SetPostHidden"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tag, err := s.Conn.Exec(
+		ctx,
+		`UPDATE posts SET hidden = $2, updated_at = NOW() WHERE post_id = $1`,
+		postId, hidden,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, translate(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return custom_errors.ErrPostDoesNotExist
 	}
 	return nil
 }
@@ -0,0 +1,331 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"new_service/internal/config"
+	"new_service/internal/lib/password"
+	"new_service/internal/models"
+	custom_errors "new_service/internal/repository"
+	"new_service/internal/repository/migrator"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestStorage подключается к базе из TEST_POSTGRES_CONN_STRING и применяет миграции.
+// Без переменной тесты пропускаются: им нужен настоящий Postgres
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	connString := os.Getenv("TEST_POSTGRES_CONN_STRING")
+	if connString == "" {
+		t.Skip("TEST_POSTGRES_CONN_STRING is not set")
+	}
+
+	m, err := migrator.New(connString, time.Minute)
+	if err != nil {
+		t.Fatalf("migrator.New: %v", err)
+	}
+	defer m.Close()
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	hasher, err := password.NewHasher(config.PasswordHashing{Algorithm: password.AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatalf("password.NewHasher: %v", err)
+	}
+	s, err := New(connString, config.PostgresPool{MaxConns: 10, QueryTimeout: 5 * time.Second}, hasher)
+	if err != nil {
+		t.Fatalf("storage.New: %v", err)
+	}
+	t.Cleanup(s.Conn.Close)
+	return s
+}
+
+// createUser создаёт пользователя с уникальным email, чтобы тесты не зависели от данных в базе
+func createUser(t *testing.T, s *Storage) (uuid.UUID, string) {
+	t.Helper()
+
+	email := uuid.NewString() + "@example.com"
+	if err := s.SaveUser(context.Background(), email, "password", ""); err != nil {
+		t.Fatalf("SaveUser: %v", err)
+	}
+	_, userId, err := s.GetUserPasswordByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("GetUserPasswordByEmail: %v", err)
+	}
+	return userId, email
+}
+
+func TestLinkExternalIdentity(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	issuer := "https://issuer.example.com"
+
+	t.Run("existing verified email", func(t *testing.T) {
+		userId, email := createUser(t, s)
+		subject := uuid.NewString()
+
+		linked, err := s.LinkExternalIdentity(ctx, issuer, subject, email, true)
+		if err != nil {
+			t.Fatalf("LinkExternalIdentity: %v", err)
+		}
+		if linked != userId {
+			t.Errorf("linked to %s, want existing user %s", linked, userId)
+		}
+
+		// Повторный вход находит аккаунт по identity, даже если провайдер сменил email
+		again, err := s.LinkExternalIdentity(ctx, issuer, subject, "changed-"+email, false)
+		if err != nil {
+			t.Fatalf("second LinkExternalIdentity: %v", err)
+		}
+		if again != userId {
+			t.Errorf("second login linked to %s, want %s", again, userId)
+		}
+	})
+
+	t.Run("existing unverified email", func(t *testing.T) {
+		_, email := createUser(t, s)
+
+		_, err := s.LinkExternalIdentity(ctx, issuer, uuid.NewString(), email, false)
+		if !errors.Is(err, custom_errors.ErrEmailNotVerified) {
+			t.Errorf("err = %v, want ErrEmailNotVerified", err)
+		}
+	})
+
+	t.Run("deleted user placeholder", func(t *testing.T) {
+		_, err := s.LinkExternalIdentity(ctx, issuer, uuid.NewString(), "deleted@bloggery.invalid", true)
+		if !errors.Is(err, custom_errors.ErrAccountUnavailable) {
+			t.Errorf("err = %v, want ErrAccountUnavailable", err)
+		}
+	})
+
+	t.Run("account scheduled for deletion", func(t *testing.T) {
+		userId, email := createUser(t, s)
+		if _, err := s.ScheduleUserDeletion(ctx, userId, "delete", time.Hour); err != nil {
+			t.Fatalf("ScheduleUserDeletion: %v", err)
+		}
+
+		_, err := s.LinkExternalIdentity(ctx, issuer, uuid.NewString(), email, true)
+		if !errors.Is(err, custom_errors.ErrAccountUnavailable) {
+			t.Errorf("err = %v, want ErrAccountUnavailable", err)
+		}
+	})
+
+	t.Run("new email", func(t *testing.T) {
+		email := uuid.NewString() + "@example.com"
+
+		created, err := s.LinkExternalIdentity(ctx, issuer, uuid.NewString(), email, true)
+		if err != nil {
+			t.Fatalf("LinkExternalIdentity: %v", err)
+		}
+		_, userId, err := s.GetUserPasswordByEmail(ctx, email)
+		if err != nil {
+			t.Fatalf("GetUserPasswordByEmail: %v", err)
+		}
+		if created != userId {
+			t.Errorf("created user %s, want %s", created, userId)
+		}
+	})
+}
+
+// fakeBlobs запоминает удалённые префиксы; err имитирует недоступное хранилище
+type fakeBlobs struct {
+	deleted []string
+	err     error
+}
+
+func (f *fakeBlobs) DeletePrefix(ctx context.Context, prefix string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.deleted = append(f.deleted, prefix)
+	return nil
+}
+
+// createMedia сохраняет запись о файле пользователя и возвращает её ключ в хранилище
+func createMedia(t *testing.T, s *Storage, userId uuid.UUID) string {
+	t.Helper()
+
+	mediaId := uuid.New()
+	media := models.Media{
+		MediaId:     mediaId,
+		UserId:      userId,
+		StorageKey:  userId.String() + "/" + mediaId.String(),
+		ContentType: "image/png",
+		Size:        1,
+		Status:      models.MediaReady,
+	}
+	if err := s.CreateMedia(context.Background(), &media, 1<<20); err != nil {
+		t.Fatalf("CreateMedia: %v", err)
+	}
+	return media.StorageKey
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	t.Run("blob store unavailable", func(t *testing.T) {
+		userId, _ := createUser(t, s)
+		createMedia(t, s, userId)
+		if _, err := s.ScheduleUserDeletion(ctx, userId, "delete", 0); err != nil {
+			t.Fatalf("ScheduleUserDeletion: %v", err)
+		}
+
+		blobs := &fakeBlobs{err: errors.New("storage is down")}
+		if _, err := s.PurgeDeletedUsers(ctx, blobs, 100); err == nil {
+			t.Fatal("PurgeDeletedUsers succeeded although files were not deleted")
+		}
+		if _, err := s.GetUser(ctx, userId); err != nil {
+			t.Errorf("user must survive a failed purge, GetUser: %v", err)
+		}
+	})
+
+	t.Run("files are deleted with the account", func(t *testing.T) {
+		userId, _ := createUser(t, s)
+		keys := []string{createMedia(t, s, userId), createMedia(t, s, userId)}
+		if _, err := s.ScheduleUserDeletion(ctx, userId, "delete", 0); err != nil {
+			t.Fatalf("ScheduleUserDeletion: %v", err)
+		}
+
+		blobs := &fakeBlobs{}
+		if _, err := s.PurgeDeletedUsers(ctx, blobs, 100); err != nil {
+			t.Fatalf("PurgeDeletedUsers: %v", err)
+		}
+		for _, key := range keys {
+			if !slices.Contains(blobs.deleted, key) {
+				t.Errorf("files under %q were not deleted", key)
+			}
+		}
+		if _, err := s.GetUser(ctx, userId); !errors.Is(err, custom_errors.ErrUserDoesNotExist) {
+			t.Errorf("GetUser after purge: err = %v, want ErrUserDoesNotExist", err)
+		}
+	})
+
+	t.Run("batches are limited", func(t *testing.T) {
+		for range 2 {
+			userId, _ := createUser(t, s)
+			if _, err := s.ScheduleUserDeletion(ctx, userId, "delete", 0); err != nil {
+				t.Fatalf("ScheduleUserDeletion: %v", err)
+			}
+		}
+
+		var total int64
+		for {
+			purged, err := s.PurgeDeletedUsers(ctx, &fakeBlobs{}, 1)
+			if err != nil {
+				t.Fatalf("PurgeDeletedUsers: %v", err)
+			}
+			if purged > 1 {
+				t.Fatalf("PurgeDeletedUsers purged %d accounts, limit is 1", purged)
+			}
+			if purged == 0 {
+				break
+			}
+			total += purged
+		}
+		if total < 2 {
+			t.Errorf("purged %d accounts, want at least 2", total)
+		}
+	})
+}
+
+func TestSaveUserReservedUsername(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	userId, _ := createUser(t, s)
+	oldUsername := "u" + strings.ReplaceAll(uuid.NewString(), "-", "")[:20]
+	newUsername := oldUsername + "_new"
+	if err := s.UpdateProfile(ctx, userId, models.ProfileUpdate{Username: &oldUsername}, time.Hour); err != nil {
+		t.Fatalf("set username: %v", err)
+	}
+	if err := s.UpdateProfile(ctx, userId, models.ProfileUpdate{Username: &newUsername}, time.Hour); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	err := s.SaveUser(ctx, uuid.NewString()+"@example.com", "password", oldUsername)
+	if !errors.Is(err, custom_errors.ErrUsernameTaken) {
+		t.Errorf("registering a redirected username: err = %v, want ErrUsernameTaken", err)
+	}
+}
+
+func TestGetPublicProfileCounts(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	userId, _ := createUser(t, s)
+	username := "u" + strings.ReplaceAll(uuid.NewString(), "-", "")[:20]
+	if err := s.UpdateProfile(ctx, userId, models.ProfileUpdate{Username: &username}, time.Hour); err != nil {
+		t.Fatalf("set username: %v", err)
+	}
+	first, _ := createUser(t, s)
+	second, _ := createUser(t, s)
+
+	// Подписок пока нет в API, поэтому строки добавляются напрямую
+	for _, follow := range [][2]uuid.UUID{{first, userId}, {second, userId}, {userId, first}} {
+		if _, err := s.Conn.Exec(ctx, `INSERT INTO follows(follower_id, followee_id) VALUES($1, $2)`, follow[0], follow[1]); err != nil {
+			t.Fatalf("insert follow: %v", err)
+		}
+	}
+
+	profile, err := s.GetPublicProfile(ctx, username)
+	if err != nil {
+		t.Fatalf("GetPublicProfile: %v", err)
+	}
+	if profile.FollowersCount != 2 || profile.FollowingCount != 1 {
+		t.Errorf("followers = %d, following = %d, want 2 and 1", profile.FollowersCount, profile.FollowingCount)
+	}
+}
+
+func TestCreateMediaQuotaConcurrent(t *testing.T) {
+	s := newTestStorage(t)
+	userId, _ := createUser(t, s)
+
+	const (
+		uploads = 10
+		size    = 30
+		quota   = 100
+	)
+	errs := make(chan error, uploads)
+	var wg sync.WaitGroup
+	for range uploads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mediaId := uuid.New()
+			errs <- s.CreateMedia(context.Background(), &models.Media{
+				MediaId:     mediaId,
+				UserId:      userId,
+				StorageKey:  userId.String() + "/" + mediaId.String(),
+				ContentType: "image/png",
+				Size:        size,
+				Status:      models.MediaPending,
+			}, quota)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	accepted := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			accepted++
+		case !errors.Is(err, custom_errors.ErrQuotaExceeded):
+			t.Errorf("CreateMedia: %v", err)
+		}
+	}
+	if accepted != quota/size {
+		t.Errorf("accepted %d uploads of %d bytes with quota %d, want %d", accepted, size, quota, quota/size)
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE posts DROP COLUMN IF EXISTS hidden;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,7 @@
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_user_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE RESTRICT;
+
+-- Служебный пользователь остаётся: ему могут принадлежать анонимизированные посты
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_mode;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_scheduled_at;
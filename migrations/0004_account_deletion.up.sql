@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_mode VARCHAR(16)
+    CHECK (deletion_mode IN ('delete', 'anonymize'));
+
+-- Владелец анонимизированных постов удалённых пользователей
+INSERT INTO users(user_id, email, password_hash)
+VALUES ('00000000-0000-0000-0000-000000000000', 'deleted@bloggery.invalid', '')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_user_id_fkey;
+ALTER TABLE posts ADD CONSTRAINT posts_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS follows;
+DROP TABLE IF EXISTS username_redirects;
+
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_url;
+ALTER TABLE users DROP COLUMN IF EXISTS website;
+ALTER TABLE users DROP COLUMN IF EXISTS bio;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
@@ -0,0 +1,21 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio VARCHAR(500);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS website VARCHAR(256);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_url VARCHAR(512);
+
+CREATE TABLE IF NOT EXISTS username_redirects(
+    old_username VARCHAR(256) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_redirects_user_id ON username_redirects (user_id);
+
+CREATE TABLE IF NOT EXISTS follows(
+    follower_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (follower_id, followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_follows_followee_id ON follows (followee_id);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS media(
+    media_id UUID PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    storage_key TEXT NOT NULL,
+    content_type VARCHAR(128) NOT NULL,
+    size BIGINT NOT NULL CHECK (size >= 0),
+    url TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_user_id ON media (user_id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_media_status;
+
+ALTER TABLE media DROP COLUMN IF EXISTS processed_at;
+ALTER TABLE media DROP COLUMN IF EXISTS error;
+ALTER TABLE media DROP COLUMN IF EXISTS variants;
+ALTER TABLE media DROP COLUMN IF EXISTS height;
+ALTER TABLE media DROP COLUMN IF EXISTS width;
+ALTER TABLE media DROP COLUMN IF EXISTS status;
@@ -0,0 +1,9 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'ready'
+    CHECK (status IN ('pending', 'processing', 'ready', 'failed'));
+ALTER TABLE media ADD COLUMN IF NOT EXISTS width INT;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS height INT;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS variants JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE media ADD COLUMN IF NOT EXISTS error TEXT;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS processed_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_media_status ON media (status) WHERE status IN ('pending', 'processing');
@@ -0,0 +1,92 @@
+package jwt_auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"new_service/internal/config"
+	"new_service/internal/lib/apperr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSRFMiddleware реализует double-submit cookie: для небезопасных методов
+// значение заголовка должно совпадать с cookie, которую не может прочитать чужой сайт.
+// Запросы с Bearer-токеном не используют cookie и не подвержены CSRF
+func CSRFMiddleware(cfg config.CSRF, cookie config.Cookie) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.Disabled {
+			c.Next()
+			return
+		}
+		if _, ok := BearerToken(c); ok {
+			c.Next()
+			return
+		}
+
+		token, err := c.Cookie(cfg.CookieName)
+		if err != nil || token == "" {
+			token, err = newCSRFToken()
+			if err != nil {
+				c.Error(apperr.From(err, "internal server error"))
+				c.Abort()
+				return
+			}
+			c.SetSameSite(cookie.SameSiteMode())
+			c.SetCookie(cfg.CookieName, token, 0, "/", cookie.Domain, cookie.Secure, false)
+		}
+		c.Header(cfg.HeaderName, token)
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			c.Next()
+			return
+		}
+
+		if !validCSRFToken(c, cfg, token) {
+			c.Error(apperr.Forbidden("invalid csrf token"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireCSRFToken проверяет CSRF-токен для любого метода. Нужен устаревшим
+// GET-маршрутам, которые меняют состояние, и ставится после CSRFMiddleware
+func RequireCSRFToken(cfg config.CSRF) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.Disabled {
+			c.Next()
+			return
+		}
+		if _, ok := BearerToken(c); ok {
+			c.Next()
+			return
+		}
+
+		token, err := c.Cookie(cfg.CookieName)
+		if err != nil || token == "" || !validCSRFToken(c, cfg, token) {
+			c.Error(apperr.Forbidden("invalid csrf token"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func validCSRFToken(c *gin.Context, cfg config.CSRF, token string) bool {
+	header := c.GetHeader(cfg.HeaderName)
+	return header != "" && subtle.ConstantTimeCompare([]byte(header), []byte(token)) == 1
+}
+
+func newCSRFToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
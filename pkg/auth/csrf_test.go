@@ -0,0 +1,63 @@
+package jwt_auth
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"new_service/internal/config"
+	"new_service/internal/lib/apperr"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireCSRFToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	csrf := config.CSRF{CookieName: "csrf_token", HeaderName: "X-CSRF-Token"}
+
+	tests := []struct {
+		name       string
+		disabled   bool
+		cookie     string
+		header     string
+		bearer     bool
+		wantStatus int
+	}{
+		{name: "matching header", cookie: "token", header: "token", wantStatus: http.StatusNoContent},
+		{name: "no header", cookie: "token", wantStatus: http.StatusForbidden},
+		{name: "other header", cookie: "token", header: "other", wantStatus: http.StatusForbidden},
+		{name: "no cookie", header: "token", wantStatus: http.StatusForbidden},
+		{name: "bearer token", bearer: true, wantStatus: http.StatusNoContent},
+		{name: "csrf disabled", disabled: true, wantStatus: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := csrf
+			cfg.Disabled = tt.disabled
+
+			router := gin.New()
+			router.Use(apperr.Middleware(slog.New(slog.NewTextHandler(io.Discard, nil))))
+			router.GET("/logout", RequireCSRFToken(cfg), func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/logout", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: cfg.CookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				req.Header.Set(cfg.HeaderName, tt.header)
+			}
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer token")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -1,8 +1,16 @@
 package jwt_auth
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"log/slog"
+	"new_service/internal/config"
+	"new_service/internal/lib/apperr"
+	sl "new_service/internal/lib/logger"
+	"new_service/internal/lib/metrics"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,22 +19,124 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-func MakeJwtToken(secretKey string, user_id uuid.UUID) (string, error) {
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+const TokenTTL = 24 * time.Hour
+
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
+}
+
+func MakeJwtToken(secretKey string, user_id uuid.UUID, role string) (string, error) {
+	tokenString, _, err := MakeJwtTokenWithId(secretKey, user_id, role)
+	return tokenString, err
+}
+
+// MakeJwtTokenWithId дополнительно возвращает jti выпущенного токена.
+// iat_ms нужен для отзыва сессий: iat хранит только секунды
+func MakeJwtTokenWithId(secretKey string, user_id uuid.UUID, role string) (string, string, error) {
 	jti := uuid.NewString()
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"user_id": user_id,
+		"role":    role,
 		"jti":     jti,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-		"iat":     time.Now().Unix(),
+		"exp":     now.Add(TokenTTL).Unix(),
+		"iat":     now.Unix(),
+		"iat_ms":  now.UnixMilli(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(secretKey))
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+
+	return tokenString, jti, nil
+}
+
+func SetTokenCookie(c *gin.Context, cookie config.Cookie, token string) {
+	c.SetSameSite(cookie.SameSiteMode())
+	c.SetCookie(
+		"jwt_token",
+		token,
+		int(TokenTTL.Seconds()),
+		"/",
+		cookie.Domain,
+		cookie.Secure,
+		true,
+	)
+}
+
+func ClearTokenCookie(c *gin.Context, cookie config.Cookie) {
+	c.SetSameSite(cookie.SameSiteMode())
+	c.SetCookie("jwt_token", "", -1, "/", cookie.Domain, cookie.Secure, true)
+}
+
+func BearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
 	}
+	return strings.TrimSpace(header[7:]), true
+}
+
+// TokenFromRequest отдаёт приоритет заголовку Authorization, иначе берёт токен из cookie
+func TokenFromRequest(c *gin.Context) (string, bool) {
+	if token, ok := BearerToken(c); ok {
+		return token, token != ""
+	}
+	cookie, err := c.Cookie("jwt_token")
+	if err != nil || cookie == "" {
+		return "", false
+	}
+	return cookie, true
+}
 
-	return tokenString, nil
+func sessionsRevokedKey(user_id string) string {
+	return "sessions_revoked_before:" + user_id
+}
+
+// RevokeUserSessions отзывает все токены пользователя, выпущенные до текущей миллисекунды.
+// Токен с keepJti остаётся действительным: так сессия, в которой сменили пароль,
+// продолжает работать с только что выпущенным токеном
+func RevokeUserSessions(ctx context.Context, rdb *redis.Client, user_id uuid.UUID, keepJti string) error {
+	value := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if keepJti != "" {
+		value += ":" + keepJti
+	}
+	if err := rdb.Set(ctx, sessionsRevokedKey(user_id.String()), value, TokenTTL).Err(); err != nil {
+		return err
+	}
+	metrics.TokensRevoked.WithLabelValues("all_sessions").Inc()
+	return nil
+}
+
+// sessionRevoked сравнивает время выпуска токена с отметкой отзыва "<ms>[:<jti>]".
+// Отметки, записанные до перехода на миллисекунды, хранят секунды
+func sessionRevoked(claims jwt.MapClaims, jti string, value string) bool {
+	revokedAt, keepJti, _ := strings.Cut(value, ":")
+	revokedBefore, err := strconv.ParseInt(revokedAt, 10, 64)
+	if err != nil {
+		return true
+	}
+	if revokedBefore < 1e11 {
+		revokedBefore *= 1000
+	}
+	if keepJti != "" && jti == keepJti {
+		return false
+	}
+
+	issuedAt, ok := claims["iat_ms"].(float64)
+	if !ok {
+		iat, _ := claims["iat"].(float64)
+		issuedAt = iat * 1000
+	}
+	return int64(issuedAt) <= revokedBefore
 }
 
 func GetClaim(token *jwt.Token, key string) (string, error) {
@@ -63,9 +173,10 @@ func GetExpFromRawToken(token string, jwt_secret string) (int64, error) {
 
 func JWTAuthMiddleware(jwt_secret string, rdb *redis.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		cookie, err := c.Cookie("jwt_token")
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "unathorized user"})
+		cookie, ok := TokenFromRequest(c)
+		if !ok {
+			c.Error(apperr.Unauthorized("unathorized user"))
+			c.Abort()
 			return
 		}
 
@@ -78,34 +189,77 @@ func JWTAuthMiddleware(jwt_secret string, rdb *redis.Client) gin.HandlerFunc {
 		})
 
 		if err != nil || !token.Valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "invalid token"})
+			c.Error(apperr.Unauthorized("invalid token"))
+			c.Abort()
 			return
 		}
 
 		user_id, err := GetClaim(token, "user_id")
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "user id is missing"})
+			c.Error(apperr.Unauthorized("user id is missing"))
+			c.Abort()
 			return
 		}
 
 		jti, err := GetClaim(token, "jti")
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "jti is missing"})
+			c.Error(apperr.Unauthorized("jti is missing"))
+			c.Abort()
 			return
 		}
 
 		exists, err := rdb.Exists(c.Request.Context(), jti).Result()
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": "internal server error"})
+			c.Error(apperr.From(err, "internal server error"))
+			c.Abort()
 			return
 		}
 		if exists == 1 {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "you have logged out"})
+			c.Error(apperr.Unauthorized("you have logged out"))
+			c.Abort()
+			return
+		}
+
+		revoked, err := rdb.Get(c.Request.Context(), sessionsRevokedKey(user_id)).Result()
+		if err != nil && err != redis.Nil {
+			c.Error(apperr.From(err, "internal server error"))
+			c.Abort()
 			return
 		}
+		if err == nil {
+			claims, _ := token.Claims.(jwt.MapClaims)
+			if sessionRevoked(claims, jti, revoked) {
+				c.Error(apperr.Unauthorized("session has been revoked"))
+				c.Abort()
+				return
+			}
+		}
+
+		// Токены, выпущенные до появления ролей, считаем токенами обычного пользователя
+		role, err := GetClaim(token, "role")
+		if err != nil {
+			role = RoleUser
+		}
 
 		c.Set("user_id", user_id)
 		c.Set("jti", jti)
+		c.Set("role", role)
+		sl.With(c, slog.String("user_id", user_id))
+		c.Next()
+	}
+}
+
+func HasRole(c *gin.Context, roles ...string) bool {
+	return slices.Contains(roles, c.GetString("role"))
+}
+
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(c, roles...) {
+			c.Error(apperr.Forbidden("insufficient role"))
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,97 @@
+package jwt_auth
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"new_service/internal/lib/apperr"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSessionRevoked(t *testing.T) {
+	const revokedAt = 1_700_000_000_500
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		jti    string
+		value  string
+		want   bool
+	}{
+		{"issued earlier in the same second", jwt.MapClaims{"iat": 1_700_000_000.0, "iat_ms": 1_700_000_000_200.0}, "a", "1700000000500", true},
+		{"issued later in the same second", jwt.MapClaims{"iat": 1_700_000_000.0, "iat_ms": 1_700_000_000_800.0}, "a", "1700000000500", false},
+		{"issued in the same millisecond", jwt.MapClaims{"iat_ms": float64(revokedAt)}, "a", "1700000000500", true},
+		{"kept token", jwt.MapClaims{"iat_ms": 1_700_000_000_200.0}, "keep", "1700000000500:keep", false},
+		{"other token next to a kept one", jwt.MapClaims{"iat_ms": 1_700_000_000_200.0}, "other", "1700000000500:keep", true},
+		{"token without iat_ms", jwt.MapClaims{"iat": 1_700_000_000.0}, "a", "1700000000500", true},
+		{"token without iat_ms issued after", jwt.MapClaims{"iat": 1_700_000_001.0}, "a", "1700000000500", false},
+		{"revocation stored in seconds", jwt.MapClaims{"iat_ms": 1_699_999_999_900.0}, "a", "1700000000", true},
+		{"token newer than a revocation in seconds", jwt.MapClaims{"iat_ms": 1_700_000_000_100.0}, "a", "1700000000", false},
+		{"malformed revocation", jwt.MapClaims{"iat_ms": 1_700_000_000_800.0}, "a", "broken", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionRevoked(tt.claims, tt.jti, tt.value); got != tt.want {
+				t.Errorf("sessionRevoked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevokeUserSessionsKeepsReissuedToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const secret = "test-secret"
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	router := gin.New()
+	router.Use(apperr.Middleware(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	router.GET("/", JWTAuthMiddleware(secret, rdb), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	status := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	userId := uuid.New()
+	old, err := MakeJwtToken(secret, userId, RoleUser)
+	if err != nil {
+		t.Fatalf("MakeJwtToken: %v", err)
+	}
+	// Новый токен выпускается в ту же секунду, что и старый, как при смене пароля
+	reissued, jti, err := MakeJwtTokenWithId(secret, userId, RoleUser)
+	if err != nil {
+		t.Fatalf("MakeJwtTokenWithId: %v", err)
+	}
+	if err := RevokeUserSessions(context.Background(), rdb, userId, jti); err != nil {
+		t.Fatalf("RevokeUserSessions: %v", err)
+	}
+
+	if got := status(old); got != http.StatusUnauthorized {
+		t.Errorf("old token: status = %d, want 401", got)
+	}
+	if got := status(reissued); got != http.StatusNoContent {
+		t.Errorf("reissued token: status = %d, want 204", got)
+	}
+
+	// Токены, выпущенные после отзыва, действительны и без исключения
+	if err := rdb.Set(context.Background(), sessionsRevokedKey(userId.String()), "1", 0).Err(); err != nil {
+		t.Fatalf("set revocation: %v", err)
+	}
+	if got := status(old); got != http.StatusNoContent {
+		t.Errorf("token issued after revocation: status = %d, want 204", got)
+	}
+}